@@ -0,0 +1,174 @@
+package alertify
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"text/template"
+)
+
+// Announcer speaks alert announcements
+type Announcer interface {
+	// Announce speaks text and returns once the announcement finishes
+	Announce(text string) error
+	// Stop stops the announcement
+	Stop() error
+	// String implements stringer interface
+	String() string
+}
+
+// renderAnnouncement renders announcement template text with alert labels
+// Labels are accessed by their names in the template, e.g. {{.alertname}}.
+func renderAnnouncement(text string, labels map[string]string) (string, error) {
+	tmpl, err := template.New("announcement").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid announcement template: %s", err)
+	}
+
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, labels); err != nil {
+		return "", fmt.Errorf("failed to render announcement: %s", err)
+	}
+
+	return buf.String(), nil
+}
+
+// announcement is the result of spoken song announcement
+type announcement struct {
+	// song is the announced song
+	song *Song
+	// doneChan stops the announced alert
+	doneChan chan struct{}
+	// err is the announcement error
+	err error
+	// resp receives the result of the announced alert
+	resp chan interface{}
+}
+
+// respond sends the result of the announced alert to whoever waits for it
+func (a *announcement) respond(result *AlertResult, err error) {
+	if a.resp == nil {
+		return
+	}
+
+	if result != nil {
+		a.resp <- result
+		return
+	}
+	a.resp <- err
+}
+
+// announce starts speaking song announcement in the background
+// It returns alert result if the announcement has started. The announcement is tracked
+// like alert playback, so silencing the alert stops it. Once it finishes, the message
+// listener plays the song unless the song is only announced. The result of the song
+// is sent to the announced channel of the returned alert result.
+func (b *Bot) announce(song *Song) (*AlertResult, bool) {
+	if song.Announcement == "" {
+		return nil, false
+	}
+
+	if b.announcer == nil {
+		log.Printf("No announcer configured, skipping announcement")
+		return nil, false
+	}
+
+	log.Printf("Announcing alert with %s", b.announcer)
+
+	b.stopAlertTasks()
+	doneChan := b.startAlertTasks()
+
+	b.Lock()
+	b.announcing = true
+	b.Unlock()
+
+	// response channel is buffered so the bot doesn't block on it
+	resp := make(chan interface{}, 1)
+	go b.speak(song, doneChan, resp)
+
+	return &AlertResult{
+		Song:      song.URI,
+		Devices:   []*DeviceResult{{Device: b.announcer.String()}},
+		announced: resp,
+	}, true
+}
+
+// speak speaks song announcement and sends its result to the message listener
+// It stops when doneChan is closed. The result of the announced alert is sent to resp.
+func (b *Bot) speak(song *Song, doneChan chan struct{}, resp chan interface{}) {
+	err := b.announcer.Announce(song.Announcement)
+
+	a := &announcement{song: song, doneChan: doneChan, err: err, resp: resp}
+	msg := &Msg{
+		Cmd:  "alert.announced",
+		Data: a,
+		// response channel is buffered so the bot doesn't block on it
+		Resp: make(chan interface{}, 1),
+	}
+
+	select {
+	case b.msgChan <- msg:
+	case <-doneChan:
+		a.respond(nil, fmt.Errorf("alert stopped during its announcement"))
+	case <-b.closeMsgChan:
+		a.respond(nil, fmt.Errorf("bot stopped during alert announcement"))
+	}
+}
+
+// announced plays the announced song once its announcement finishes and responds with its result
+// It does nothing if the alert has been silenced or another alert has started playing in the meantime.
+func (b *Bot) announced(a *announcement) error {
+	b.Lock()
+	current := a.doneChan != nil && b.alertDone == a.doneChan
+	if current {
+		b.announcing = false
+	}
+	b.Unlock()
+
+	if !current {
+		a.respond(nil, fmt.Errorf("alert stopped during its announcement"))
+		return nil
+	}
+
+	b.stopAlertTasks()
+
+	if a.err != nil {
+		// the song is played even if the announcement was meant to replace it
+		log.Printf("Failed to announce alert: %s", a.err)
+	} else if a.song.AnnounceOnly {
+		a.respond(&AlertResult{
+			Song:    a.song.URI,
+			Devices: []*DeviceResult{{Device: b.announcer.String()}},
+		}, nil)
+		return nil
+	}
+
+	song := *a.song
+	song.Announcement = ""
+
+	result, err := b.AlertSong(&song)
+	a.respond(result, err)
+
+	return err
+}
+
+// stopAnnouncement stops the announcement which is being spoken
+// It returns false if no announcement is being spoken.
+func (b *Bot) stopAnnouncement() (bool, error) {
+	b.Lock()
+	announcing := b.announcing
+	b.announcing = false
+	b.Unlock()
+
+	if !announcing {
+		return false, nil
+	}
+
+	b.stopAlertTasks()
+
+	return true, b.announcer.Stop()
+}
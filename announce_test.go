@@ -0,0 +1,107 @@
+package alertify
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAnnouncer speaks announcements once they are released
+type fakeAnnouncer struct {
+	// release finishes the announcement
+	release chan struct{}
+}
+
+// Announce waits for the announcement to be released
+func (a *fakeAnnouncer) Announce(text string) error {
+	<-a.release
+	return nil
+}
+
+// Stop does nothing
+func (a *fakeAnnouncer) Stop() error {
+	return nil
+}
+
+// String returns the name of the announcer
+func (a *fakeAnnouncer) String() string {
+	return "Fake Announcer"
+}
+
+func TestBotAnnouncedAlertResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		stale      bool
+		wantDevice string
+		wantErr    bool
+	}{
+		{
+			name:       "announced",
+			wantDevice: "Fake Announcer",
+		},
+		{
+			// alert silenced during the announcement must not report it as played
+			name:    "stopped during announcement",
+			stale:   true,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			announcer := &fakeAnnouncer{release: make(chan struct{})}
+			b := &Bot{
+				Mutex:        &sync.Mutex{},
+				announcer:    announcer,
+				msgChan:      make(chan *Msg),
+				closeMsgChan: make(chan struct{}),
+			}
+
+			resp := make(chan interface{}, 1)
+			b.processMsg(&Msg{
+				Cmd: "alert",
+				Data: &Song{
+					URI:          "spotify:track:7yTIKQzqRQfXDKKiPw3GJY",
+					Announcement: "alert firing",
+					AnnounceOnly: true,
+				},
+				Resp: resp,
+			})
+
+			select {
+			case r := <-resp:
+				t.Fatalf("alert responded before its announcement finished: %v", r)
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			if tt.stale {
+				b.stopAlertTasks()
+				b.startAlertTasks()
+			}
+			close(announcer.release)
+
+			// the bot processes the finished announcement unless it has been stopped in the meantime
+			var r interface{}
+			select {
+			case msg := <-b.msgChan:
+				b.processMsg(msg)
+				r = <-resp
+			case r = <-resp:
+			case <-time.After(time.Second):
+				t.Fatal("announced alert did not respond")
+			}
+
+			if err := RespError(r); (err != nil) != tt.wantErr {
+				t.Fatalf("alert response error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			result, ok := r.(*AlertResult)
+			if !ok || len(result.Devices) != 1 || result.Devices[0].Device != tt.wantDevice {
+				t.Errorf("alert response = %v, want result played on %s", r, tt.wantDevice)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package alertify
 
 import (
+	"encoding/json"
+	"io"
 	"log"
 	"net/http"
 	"time"
@@ -13,6 +15,9 @@ const (
 	APIVERSION = "v1"
 	// Timeout is bot response timeout
 	Timeout = 3 * time.Second
+	// AlertTimeout is bot response timeout of alert requests
+	// Announced alerts respond once the announcement has finished and the song has started playing.
+	AlertTimeout = 30 * time.Second
 )
 
 type handler func(c *Context, w http.ResponseWriter, r *http.Request)
@@ -23,8 +28,17 @@ func apiRoutes() routes {
 	return routes{
 		APIVERSION: {
 			"POST": {
-				"/alert/play":    alertPlay,
-				"/alert/silence": alertSilence,
+				"/alert/play":             alertPlay,
+				"/alert/silence":          alertSilence,
+				"/monitors":               monitorAdd,
+				"/monitors/{name}/pause":  monitorPause,
+				"/monitors/{name}/resume": monitorResume,
+			},
+			"GET": {
+				"/monitors": monitorList,
+			},
+			"DELETE": {
+				"/monitors/{name}": monitorRemove,
 			},
 		},
 	}
@@ -51,62 +65,159 @@ func newRouter(c *Context) *mux.Router {
 	return r
 }
 
-func alertPlay(c *Context, w http.ResponseWriter, r *http.Request) {
-	respChan := make(chan interface{})
+// sendMsg sends command message to bot and waits for Timeout for its response
+// It returns false if the bot does not respond before Timeout expires or if it has stopped.
+func sendMsg(c *Context, cmd string, data interface{}) (interface{}, bool) {
+	return sendMsgTimeout(c, cmd, data, Timeout)
+}
+
+// sendMsgTimeout sends command message to bot and waits for timeout for its response
+// It returns false if the bot does not respond before timeout expires or if it has stopped.
+func sendMsgTimeout(c *Context, cmd string, data interface{}, timeout time.Duration) (interface{}, bool) {
+	// response channel is buffered so the bot doesn't block on timed out requests
+	respChan := make(chan interface{}, 1)
 	// ticker timeout
-	ticker := time.NewTicker(Timeout)
+	ticker := time.NewTicker(timeout)
 	defer ticker.Stop()
 
+	// sendDone stops the pending send when the request times out
+	sendDone := make(chan struct{})
+	defer close(sendDone)
+
 	go func() {
-		c.msgChan <- &Msg{"alert", nil, respChan}
+		select {
+		case c.msgChan <- &Msg{cmd, data, respChan}:
+		case <-c.closeChan:
+		case <-sendDone:
+		}
 	}()
 
 	// wait for Timeout seconds
 	select {
 	case <-ticker.C:
-		log.Printf("Alert trigger timed out")
-		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-		w.WriteHeader(http.StatusGatewayTimeout)
+		return nil, false
+	case <-c.closeChan:
+		return nil, false
+	case resp := <-respChan:
+		return resp, true
+	}
+}
+
+// writeJSON writes HTTP status code and encodes v as JSON response body
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	if v == nil {
 		return
-	case err := <-respChan:
-		if err != nil {
-			log.Printf("Failed to trigger alert: %s", err)
-			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-			w.WriteHeader(http.StatusInternalServerError)
+	}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode response: %s", err)
+	}
+}
+
+// writeResp writes bot command response as HTTP response
+func writeResp(w http.ResponseWriter, desc string, resp interface{}, ok bool) {
+	if !ok {
+		log.Printf("%s timed out", desc)
+		writeJSON(w, http.StatusGatewayTimeout, nil)
+		return
+	}
+
+	// alert results are returned even if the alert failed on some devices
+	result, isResult := resp.(*AlertResult)
+
+	if err := RespError(resp); err != nil {
+		log.Printf("Failed to %s: %s", desc, err)
+		if isResult {
+			writeJSON(w, http.StatusInternalServerError, result)
 			return
 		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	w.WriteHeader(http.StatusOK)
+	if isResult {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, nil)
+}
+
+// alertRequest is alert play API request body
+type alertRequest struct {
+	// SongURI is Spotify URI, URL or search expression of the alert song
+	SongURI string `json:"song_uri"`
+	// Labels are alert labels
+	Labels map[string]string `json:"labels"`
+}
+
+func alertPlay(c *Context, w http.ResponseWriter, r *http.Request) {
+	req := new(alertRequest)
+	// request body is optional
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil && err != io.EOF {
+		log.Printf("Failed to decode alert request: %s", err)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	alert := &Alert{Labels: req.Labels}
+	if req.SongURI != "" {
+		alert.Song = &Song{URI: req.SongURI}
+	}
+
+	resp, ok := sendMsgTimeout(c, "alert", alert, AlertTimeout)
+	writeResp(w, "trigger alert", resp, ok)
 }
 
 func alertSilence(c *Context, w http.ResponseWriter, r *http.Request) {
-	respChan := make(chan interface{})
-	// ticker timeout
-	ticker := time.NewTicker(Timeout)
-	defer ticker.Stop()
+	resp, ok := sendMsg(c, "silence", nil)
+	writeResp(w, "silence alert", resp, ok)
+}
 
-	go func() {
-		c.msgChan <- &Msg{"silence", nil, respChan}
-	}()
+func monitorList(c *Context, w http.ResponseWriter, r *http.Request) {
+	resp, ok := sendMsg(c, "monitors", nil)
+	if !ok {
+		writeResp(w, "list monitors", resp, ok)
+		return
+	}
 
-	// wait for Timeout seconds
-	select {
-	case <-ticker.C:
-		log.Printf("Alert silence timed out")
-		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-		w.WriteHeader(http.StatusGatewayTimeout)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// monitorRequest is monitor add API request body
+type monitorRequest struct {
+	// Name is a unique name of the monitor
+	Name string `json:"name"`
+	// Type is the monitor type as configured in BotConfig.MonitorTypes
+	Type string `json:"type"`
+	// Config is the monitor configuration passed to the monitor factory
+	Config json.RawMessage `json:"config"`
+}
+
+func monitorAdd(c *Context, w http.ResponseWriter, r *http.Request) {
+	req := new(monitorRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		log.Printf("Failed to decode monitor request: %s", err)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
 		return
-	case err := <-respChan:
-		if err != nil {
-			log.Printf("Failed to silence alert: %s", err)
-			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
 	}
 
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	w.WriteHeader(http.StatusOK)
+	resp, ok := sendMsg(c, "monitor.add", req)
+	writeResp(w, "add monitor", resp, ok)
+}
+
+func monitorRemove(c *Context, w http.ResponseWriter, r *http.Request) {
+	resp, ok := sendMsg(c, "monitor.remove", mux.Vars(r)["name"])
+	writeResp(w, "remove monitor", resp, ok)
+}
+
+func monitorPause(c *Context, w http.ResponseWriter, r *http.Request) {
+	resp, ok := sendMsg(c, "monitor.pause", mux.Vars(r)["name"])
+	writeResp(w, "pause monitor", resp, ok)
+}
+
+func monitorResume(c *Context, w http.ResponseWriter, r *http.Request) {
+	resp, ok := sendMsg(c, "monitor.resume", mux.Vars(r)["name"])
+	writeResp(w, "resume monitor", resp, ok)
 }
@@ -0,0 +1,55 @@
+package alertify
+
+import (
+	"math/rand"
+	"time"
+)
+
+// backoff computes jittered exponential backoff delays
+type backoff struct {
+	// min is the initial delay
+	min time.Duration
+	// max is the maximum delay
+	max time.Duration
+	// attempt is the number of delays returned since the last reset
+	attempt int
+}
+
+// newBackoff creates new backoff and returns it
+func newBackoff(min, max time.Duration) *backoff {
+	if max < min {
+		max = min
+	}
+
+	return &backoff{min: min, max: max}
+}
+
+// Next returns next backoff delay
+// The delay doubles with every attempt until it reaches max and is randomly
+// jittered to between half and the full computed delay.
+func (b *backoff) Next() time.Duration {
+	d := b.min
+	for i := 0; i < b.attempt && d < b.max; i++ {
+		d *= 2
+	}
+	if d > b.max {
+		d = b.max
+	}
+	b.attempt++
+
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int63n(half+1))
+	}
+
+	return d
+}
+
+// Attempts returns number of delays returned since the last reset
+func (b *backoff) Attempts() int {
+	return b.attempt
+}
+
+// Reset resets backoff to its initial delay
+func (b *backoff) Reset() {
+	b.attempt = 0
+}
@@ -0,0 +1,65 @@
+package alertify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffNext(t *testing.T) {
+	tests := []struct {
+		name string
+		min  time.Duration
+		max  time.Duration
+		want []time.Duration
+	}{
+		{
+			name: "exponential",
+			min:  time.Second,
+			max:  time.Minute,
+			want: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second, time.Minute, time.Minute},
+		},
+		{
+			name: "max below min",
+			min:  time.Second,
+			max:  time.Millisecond,
+			want: []time.Duration{time.Second, time.Second},
+		},
+		{
+			name: "no delay",
+			want: []time.Duration{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackoff(tt.min, tt.max)
+
+			for i, want := range tt.want {
+				// delays are jittered to between half and the full delay
+				if d := b.Next(); d < want/2 || d > want {
+					t.Errorf("delay %d = %s, want between %s and %s", i, d, want/2, want)
+				}
+				if b.Attempts() != i+1 {
+					t.Errorf("attempts = %d, want %d", b.Attempts(), i+1)
+				}
+			}
+
+			b.Reset()
+			if d := b.Next(); d < tt.want[0]/2 || d > tt.want[0] {
+				t.Errorf("delay after reset = %s, want between %s and %s", d, tt.want[0]/2, tt.want[0])
+			}
+		})
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	delays := make(map[time.Duration]bool)
+	for i := 0; i < 20; i++ {
+		b := newBackoff(time.Second, time.Minute)
+		delays[b.Next()] = true
+	}
+
+	if len(delays) < 2 {
+		t.Errorf("delays are not jittered: %v", delays)
+	}
+}
@@ -1,9 +1,12 @@
 package alertify
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 	"sync"
+	"time"
 )
 
 // Msg is allows to control aleritfy bot behavior
@@ -16,20 +19,70 @@ type Msg struct {
 	Resp chan interface{}
 }
 
+// RespError returns error carried by Bot response resp
+// Bot responds with either error or a command result which might carry an error.
+func RespError(resp interface{}) error {
+	switch r := resp.(type) {
+	case error:
+		return r
+	case *AlertResult:
+		return r.Err()
+	}
+
+	return nil
+}
+
+const (
+	// DefaultAccount is the name of the default Spotify account
+	DefaultAccount = "default"
+)
+
 // Bot plays spotify songs when requested
 type Bot struct {
-	// spotify is Spotify client
+	// spotify is Spotify client of the account the last alert was played with
 	spotify *SpotifyClient
+	// accounts are Spotify clients indexed by account names
+	accounts map[string]*SpotifyClient
 	// api is HTTP API service
 	api *API
-	// songURI is Spotify song URI
-	songURI string
+	// song is the default alert song
+	song *Song
+	// restore restores user playback after the alert
+	restore bool
+	// playback is user playback saved before the alert
+	playback *Playback
+	// volume is device volume saved before the alert
+	volume *int
+	// alertDone stops background tasks of the playing alert
+	alertDone chan struct{}
+	// group is the device group the playing alert has been played on
+	group string
+	// device is the device the playing alert has been played on
+	device string
+	// routes configure alert playback based on alert labels
+	routes []*Route
+	// players are registered alert players indexed by their names
+	players map[string]Player
+	// playing is the name of the player which plays the current alert
+	playing string
+	// announcer speaks alert announcements
+	announcer Announcer
+	// announcing is true while the alert announcement is being spoken
+	announcing bool
 	// msgChan allows to send command messages to Bot
 	msgChan chan *Msg
 	// closeMsgChan stops Bot message listener
 	closeMsgChan chan struct{}
-	// monitors are Bot monitors
-	monitors []Monitor
+	// monitors are Bot monitors indexed by their names
+	monitors map[string]*monitorEntry
+	// monitorTypes are monitor factories indexed by monitor types
+	monitorTypes map[string]MonitorFactory
+	// errChan receives monitor errors when bot is running
+	errChan chan error
+	// supervisor configures monitor supervision
+	supervisor *SupervisorConfig
+	// wg keeps track of running monitors
+	wg *sync.WaitGroup
 	// isRunning checks if bot is running
 	isRunning bool
 	// mutex
@@ -38,80 +91,727 @@ type Bot struct {
 
 // BotConfig configures alertify bot
 type BotConfig struct {
-	// Spotify configures Spotify API client
+	// Spotify configures Spotify API client of the default account
 	Spotify *SpotifyConfig
+	// Accounts configure Spotify API clients of additional named Spotify accounts
+	Accounts map[string]*SpotifyConfig
+	// Supervisor configures monitor supervision
+	Supervisor *SupervisorConfig
+	// Players are additional alert players indexed by their names.
+	// Songs select the player they are played with by its name.
+	Players map[string]Player
+	// FallbackPlayer is the name of the player used when the alert fails to play
+	FallbackPlayer string
+	// Announcer speaks song announcements before the songs are played
+	Announcer Announcer
+	// Routes configure alert playback based on alert labels.
+	// The first route matching alert labels is used.
+	Routes []*Route
+	// MonitorTypes are monitor factories indexed by monitor types.
+	// They create monitors added via API while the bot is running.
+	MonitorTypes map[string]MonitorFactory
 }
 
 // NewBot creates new alertify bot and returns it
 // It fails with error if neither of the following couldnt be created:
 // Spotify API client, Slack API client, HTTP API service
 func NewBot(c *BotConfig) (*Bot, error) {
+	players := make(map[string]Player)
+	for name, p := range c.Players {
+		if name == SpotifyPlayer {
+			return nil, fmt.Errorf("player name %s is reserved", name)
+		}
+		players[name] = p
+	}
+
 	// Create Spotify client and set Spotify Device ID
 	spotifyClient, err := NewSpotifyClient(c.Spotify)
 	if err != nil {
 		return nil, err
 	}
+	// Create Spotify clients of all additional accounts
+	accounts := map[string]*SpotifyClient{DefaultAccount: spotifyClient}
+	for name, config := range c.Accounts {
+		if _, ok := accounts[name]; ok {
+			return nil, fmt.Errorf("duplicate Spotify account: %s", name)
+		}
+		log.Printf("Creating Spotify client for account %s", name)
+		client, err := NewSpotifyClient(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Spotify client for account %s: %s", name, err)
+		}
+		accounts[name] = client
+	}
 	// create message channel
 	msgChan := make(chan *Msg)
 	// create close message channel
 	closeMsgChan := make(chan struct{})
 	// Create HTTP API
-	api, err := NewAPI(&Context{msgChan}, ":8080", nil)
+	api, err := NewAPI(&Context{msgChan, closeMsgChan}, ":8080", nil)
 	if err != nil {
 		return nil, err
 	}
 
-	// monitors keeps a list of registered monitors
-	monitors := make([]Monitor, 0)
+	// monitors keeps track of registered monitors
+	monitors := make(map[string]*monitorEntry)
+
+	supervisor := c.Supervisor
+	if supervisor == nil {
+		supervisor = defaultSupervisorConfig()
+	}
+
+	bot := &Bot{
+		spotify:  spotifyClient,
+		accounts: accounts,
+		api:      api,
+		song: &Song{
+			URI:     c.Spotify.SongURI,
+			Shuffle: c.Spotify.Shuffle,
+			Offset:  c.Spotify.Offset,
+			Random:  c.Spotify.RandomTrack,
 
-	return &Bot{
-		spotify:      spotifyClient,
-		api:          api,
-		songURI:      c.Spotify.SongURI,
+			Volume:         c.Spotify.Volume,
+			RelativeVolume: c.Spotify.RelativeVolume,
+			VolumeRamp:     c.Spotify.VolumeRamp,
+			PositionMs:     c.Spotify.PositionMs,
+			MaxDuration:    c.Spotify.MaxDuration,
+
+			FallbackPlayer: c.FallbackPlayer,
+		},
+		players:      players,
+		announcer:    c.Announcer,
+		routes:       c.Routes,
+		restore:      c.Spotify.RestorePlayback,
 		msgChan:      msgChan,
 		closeMsgChan: closeMsgChan,
 		monitors:     monitors,
+		monitorTypes: c.MonitorTypes,
+		supervisor:   supervisor,
+		wg:           &sync.WaitGroup{},
 		isRunning:    false,
 		Mutex:        &sync.Mutex{},
-	}, nil
+	}
+
+	if err := bot.resolveSongs(); err != nil {
+		if err := api.l.Close(); err != nil {
+			log.Printf("Error closing API listener: %v", err)
+		}
+		return nil, err
+	}
+
+	return bot, nil
 }
 
 // Alert plays songURI song on Spotify
+// If songURI is empty, the default alert song is played.
 func (b *Bot) Alert(songURI string) error {
-	if songURI == "" {
-		songURI = b.songURI
+	_, err := b.Notify(&Alert{Song: &Song{URI: songURI}})
+	return err
+}
+
+// Notify plays the alert song selected by the alert route on Spotify
+// Song announcement is rendered with the alert labels.
+func (b *Bot) Notify(a *Alert) (*AlertResult, error) {
+	song := b.alertSong(a)
+
+	if song.Announcement != "" {
+		text, err := renderAnnouncement(song.Announcement, a.Labels)
+		if err != nil {
+			log.Printf("Skipping alert announcement: %s", err)
+		}
+		song.Announcement = text
+	}
+
+	return b.AlertSong(song)
+}
+
+// AlertSong plays song and returns playback results per device
+// Song is played on Spotify unless it selects another registered player.
+// If the alert fails and the song has a fallback player, the song is played
+// with the fallback player instead.
+// Song announcement is announced before the song is played: the announcement is spoken
+// in the background and the song is played once it finishes.
+func (b *Bot) AlertSong(s *Song) (*AlertResult, error) {
+	song := *s
+
+	// previous announcement is interrupted by the new alert
+	if _, err := b.stopAnnouncement(); err != nil {
+		log.Printf("Failed to stop previous announcement: %s", err)
+	}
+
+	// alert played by another player keeps playing unless it's stopped
+	if _, err := b.stopPlayer(); err != nil {
+		log.Printf("Failed to stop previous alert: %s", err)
+	}
+
+	if result, ok := b.announce(&song); ok {
+		return result, nil
+	}
+
+	if song.Player != "" && song.Player != SpotifyPlayer {
+		b.stopAlertTasks()
+		result, err := b.alertPlayer(&song, song.Player)
+		if err != nil {
+			return b.fallback(&song, result, err)
+		}
+		return result, nil
+	}
+
+	result, err := b.alertSpotify(&song)
+	if err != nil {
+		return b.fallback(&song, result, err)
+	}
+
+	return result, nil
+}
+
+// alertSpotify plays song on Spotify and returns playback results per device
+// If playback restoring is enabled, the current playback is saved before the alert
+// is played and it's restored once the alert song finishes playing.
+// If the song changes the device volume, the volume is restored in the same way.
+// Songs played on device groups are played as they are: they neither change
+// device volume nor restore the previous playback.
+func (b *Bot) alertSpotify(s *Song) (*AlertResult, error) {
+	song := *s
+	if song.URI == "" {
+		song.URI = b.song.URI
+	}
+	if song.URI == "" {
+		song.URI = DefaultSongURI
+	}
+
+	client, err := b.account(song.Account)
+	if err != nil {
+		return nil, err
+	}
+
+	if song.URI, err = client.ResolveSong(song.URI); err != nil {
+		return nil, err
+	}
+
+	uri, err := ParseSpotifyURI(song.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	b.switchClient(client)
+	b.stopAlertTasks()
+
+	if song.Device != "" || song.DeviceGroup != "" {
+		return b.alertDevices(&song, uri)
+	}
+
+	if b.restore {
+		b.savePlayback()
+	}
+
+	volume, setVolume := b.prepareVolume(&song)
+
+	err = client.Play(&song)
+	result := &AlertResult{
+		Song:    uri.String(),
+		Devices: []*DeviceResult{newDeviceResult(client.Device(), "", err)},
+	}
+	if err != nil {
+		return result, err
+	}
+
+	doneChan := b.startAlertTasks()
+	if setVolume {
+		if song.VolumeRamp > 0 {
+			go b.rampVolume(volume, song.VolumeRamp, doneChan)
+		} else if err := client.SetVolume(volume); err != nil {
+			log.Printf("Failed to set Spotify device volume: %s", err)
+		}
+	}
+
+	b.Lock()
+	restore := b.playback != nil || b.volume != nil
+	b.Unlock()
+
+	if restore {
+		go b.watchPlayback(uri.String(), doneChan)
+	}
+
+	if song.MaxDuration > 0 {
+		go b.limitDuration(song.MaxDuration, doneChan)
+	}
+
+	return result, nil
+}
+
+// account returns Spotify client of the Spotify account named name
+// Empty name returns the client of the default account.
+func (b *Bot) account(name string) (*SpotifyClient, error) {
+	if name == "" {
+		name = DefaultAccount
+	}
+
+	client, ok := b.accounts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown Spotify account: %s", name)
+	}
+
+	return client, nil
+}
+
+// resolveSongs resolves search expressions of the default song and route songs
+// so that invalid songs are detected before the first alert is played.
+func (b *Bot) resolveSongs() error {
+	songs := []*Song{b.song}
+	for _, r := range b.routes {
+		if r.Song != nil {
+			songs = append(songs, mergeSong(b.song, r.Song))
+		}
+	}
+
+	for _, song := range songs {
+		if song.Player != "" && song.Player != SpotifyPlayer {
+			continue
+		}
+		client, err := b.account(song.Account)
+		if err != nil {
+			return err
+		}
+		if _, err := client.ResolveSong(song.URI); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// switchClient switches Spotify client used to play alerts to client
+// Alert which is still playing with the previous client is silenced first.
+func (b *Bot) switchClient(client *SpotifyClient) {
+	b.Lock()
+	prev := b.spotify
+	playing := b.alertDone != nil
+	b.Unlock()
+
+	if prev == client {
+		return
+	}
+
+	if playing {
+		if err := b.Silence(); err != nil {
+			log.Printf("Failed to silence previous alert: %s", err)
+		}
+	}
+
+	b.Lock()
+	b.spotify = client
+	b.Unlock()
+}
+
+// client returns Spotify client of the account the last alert was played with
+func (b *Bot) client() *SpotifyClient {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.spotify
+}
+
+// alertDevices plays song on the song device or device group
+func (b *Bot) alertDevices(song *Song, uri *SpotifyURI) (*AlertResult, error) {
+	client := b.client()
+
+	var (
+		devices  []*DeviceResult
+		turns    []string
+		interval time.Duration
+	)
+	if song.Device != "" {
+		devices = []*DeviceResult{client.PlayDevice(song, song.Device)}
+	} else {
+		group, err := client.DeviceGroup(song.DeviceGroup)
+		if err != nil {
+			return nil, err
+		}
+		if devices, err = client.PlayGroup(song, song.DeviceGroup); err != nil {
+			return nil, err
+		}
+		if group.Mode == DeviceGroupAll {
+			// the alert moves on to the devices after the one it has started playing on
+			turns, interval = group.Devices[len(devices):], group.interval()
+		}
+	}
+
+	result := &AlertResult{
+		Song:    uri.String(),
+		Devices: devices,
+	}
+	if err := result.Err(); err != nil {
+		return result, err
+	}
+
+	b.Lock()
+	b.group = song.DeviceGroup
+	b.device = song.Device
+	b.Unlock()
+
+	doneChan := b.startAlertTasks()
+	if len(turns) > 0 {
+		go b.playInTurn(song, turns, interval, doneChan)
+	}
+	if song.MaxDuration > 0 {
+		go b.limitDuration(song.MaxDuration, doneChan)
+	}
+
+	return result, nil
+}
+
+// deviceTurn is the turn of a device group device to play the alert
+type deviceTurn struct {
+	// song is the alert song
+	song *Song
+	// device is the name or ID of the device
+	device string
+	// doneChan stops background tasks of the alert
+	doneChan chan struct{}
+}
+
+// playInTurn moves the playing alert to devices one after another once it has played
+// on the previous device for interval. The alert is moved by the message listener, so that
+// no other alert can start playing in the meantime. It stops when doneChan is closed.
+func (b *Bot) playInTurn(song *Song, devices []string, interval time.Duration, doneChan chan struct{}) {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for _, device := range devices {
+		select {
+		case <-timer.C:
+		case <-doneChan:
+			return
+		}
+
+		b.sendAlertMsg("alert.turn", &deviceTurn{song, device, doneChan}, doneChan)
+		timer.Reset(interval)
+	}
+}
+
+// turnDevice plays the alert on the device whose turn it is
+// It does nothing if the alert has been silenced or if another alert has started playing.
+func (b *Bot) turnDevice(t *deviceTurn) {
+	b.Lock()
+	current := t.doneChan != nil && b.alertDone == t.doneChan
+	b.Unlock()
+
+	if !current {
+		return
+	}
+
+	res := b.client().PlayDevice(t.song, t.device)
+	if res.Error != "" {
+		log.Printf("Failed to play alert on %s: %s", res.Device, res.Error)
+		return
+	}
+
+	log.Printf("Alert moved to %s", res.Device)
+}
+
+// limitDuration silences the playing alert once duration d elapses
+// The alert is silenced by the message listener, so that no other alert can start playing
+// between checking the alert is still playing and silencing it. It stops when doneChan is closed.
+func (b *Bot) limitDuration(d time.Duration, doneChan chan struct{}) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-doneChan:
+		return
+	}
+
+	log.Printf("Alert reached maximum duration of %s", d)
+
+	b.sendAlertMsg("alert.expire", doneChan, doneChan)
+}
+
+// sendAlertMsg sends cmd message with data about the alert whose background tasks are stopped
+// by doneChan to the message listener. It gives up when doneChan is closed or when the bot stops.
+func (b *Bot) sendAlertMsg(cmd string, data interface{}, doneChan chan struct{}) {
+	msg := &Msg{
+		Cmd:  cmd,
+		Data: data,
+		// response channel is buffered so the bot doesn't block on it
+		Resp: make(chan interface{}, 1),
+	}
+
+	select {
+	case b.msgChan <- msg:
+	case <-doneChan:
+	case <-b.closeMsgChan:
 	}
-	return b.spotify.PlaySong(songURI)
 }
 
-// Silence pauses Spotify playback
+// expireAlert silences the alert whose background tasks are stopped by doneChan
+// It does nothing if another alert has started playing in the meantime.
+func (b *Bot) expireAlert(doneChan chan struct{}) error {
+	b.Lock()
+	current := doneChan != nil && b.alertDone == doneChan
+	b.Unlock()
+
+	if !current {
+		return nil
+	}
+
+	return b.Silence()
+}
+
+// Silence stops alert playback
+// Spotify playback is paused. If the playback has been saved before the alert, it's restored instead.
+// Device volume changed by the alert is restored, too.
 func (b *Bot) Silence() error {
-	return b.spotify.Pause()
+	if _, err := b.stopAnnouncement(); err != nil {
+		log.Printf("Failed to stop announcement: %s", err)
+	}
+
+	b.stopAlertTasks()
+
+	// alert played by another player is only stopped
+	if stopped, err := b.stopPlayer(); stopped {
+		return err
+	}
+
+	b.Lock()
+	client := b.spotify
+	group, device := b.group, b.device
+	b.group, b.device = "", ""
+	b.Unlock()
+
+	switch {
+	case device != "":
+		return client.PauseDevices(device)
+	case group != "":
+		return client.PauseGroup(group)
+	}
+
+	restored, err := b.restorePlayback()
+	if !restored || err != nil {
+		if err != nil {
+			log.Printf("Failed to restore Spotify playback: %s", err)
+		}
+		if err := client.Pause(); err != nil {
+			return err
+		}
+	}
+
+	return b.restoreVolume()
 }
 
-// RegisterMonitor registers remote monitor
-func (b *Bot) RegisterMonitor(monitors ...Monitor) error {
-	// add all monitors to bot list
-	b.monitors = append(b.monitors, monitors...)
+// RegisterMonitor registers remote monitor under a unique name
+// If the bot is already running the monitor is started straight away.
+// It returns error if a monitor with the same name has already been registered.
+func (b *Bot) RegisterMonitor(name string, m Monitor) error {
+	if name == "" {
+		return fmt.Errorf("empty monitor name")
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	if _, ok := b.monitors[name]; ok {
+		return fmt.Errorf("monitor %s already registered", name)
+	}
+
+	e := newMonitorEntry(name, m)
+	b.monitors[name] = e
+
+	if b.isRunning {
+		b.startMonitor(e)
+	}
 
 	return nil
 }
 
+// AddMonitor creates a monitor of type kind from its JSON config and registers it under a unique name
+// Monitor types are configured via BotConfig.MonitorTypes.
+func (b *Bot) AddMonitor(name, kind string, config json.RawMessage) error {
+	factory, ok := b.monitorTypes[kind]
+	if !ok {
+		return fmt.Errorf("unknown monitor type: %s", kind)
+	}
+
+	m, err := factory(config)
+	if err != nil {
+		return fmt.Errorf("failed to create %s monitor: %s", kind, err)
+	}
+
+	return b.RegisterMonitor(name, m)
+}
+
+// RemoveMonitor stops the monitor registered under name and removes it from bot
+func (b *Bot) RemoveMonitor(name string) error {
+	b.Lock()
+	e, ok := b.monitors[name]
+	if !ok {
+		b.Unlock()
+		return fmt.Errorf("monitor %s not found", name)
+	}
+	delete(b.monitors, name)
+	b.Unlock()
+
+	log.Printf("Shutting down %s", e)
+	e.stop()
+
+	return nil
+}
+
+// PauseMonitor pauses the monitor registered under name
+// Paused monitor keeps running, but bot ignores all of its messages.
+func (b *Bot) PauseMonitor(name string) error {
+	e, err := b.monitor(name)
+	if err != nil {
+		return err
+	}
+	e.setPaused(true)
+
+	return nil
+}
+
+// ResumeMonitor resumes the monitor registered under name
+func (b *Bot) ResumeMonitor(name string) error {
+	e, err := b.monitor(name)
+	if err != nil {
+		return err
+	}
+	e.setPaused(false)
+
+	return nil
+}
+
+// Monitors returns the status of all registered monitors sorted by name
+func (b *Bot) Monitors() []*MonitorStatus {
+	b.Lock()
+	defer b.Unlock()
+
+	statuses := make([]*MonitorStatus, 0, len(b.monitors))
+	for _, e := range b.monitors {
+		statuses = append(statuses, e.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
+}
+
+// monitor returns monitor registered under name
+func (b *Bot) monitor(name string) (*monitorEntry, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	e, ok := b.monitors[name]
+	if !ok {
+		return nil, fmt.Errorf("monitor %s not found", name)
+	}
+
+	return e, nil
+}
+
+// startMonitor starts monitor goroutines
+// It must be called with bot lock held.
+func (b *Bot) startMonitor(e *monitorEntry) {
+	e.Lock()
+	e.isRunning = true
+	e.Unlock()
+
+	b.wg.Add(2)
+	go func() {
+		defer b.wg.Done()
+		e.forward(b.msgChan)
+	}()
+	go func() {
+		defer b.wg.Done()
+		b.supervise(e, b.errChan)
+	}()
+}
+
 // processMsg processes bot message and runs bot command
 func (b *Bot) processMsg(msg *Msg) {
 	switch msg.Cmd {
 	case "alert":
-		var songURI string
-		var ok bool
-		if msg.Data != nil {
-			songURI, ok = msg.Data.(string)
-			if !ok {
-				songURI = b.songURI
-			}
+		var (
+			result *AlertResult
+			err    error
+		)
+		switch data := msg.Data.(type) {
+		case *Alert:
+			result, err = b.Notify(data)
+		case *Song:
+			result, err = b.AlertSong(data)
+		case string:
+			result, err = b.Notify(&Alert{Song: &Song{URI: data}})
+		default:
+			result, err = b.Notify(&Alert{})
 		}
-		msg.Resp <- b.Alert(songURI)
+		// announced alert responds with the result of its song once the announcement finishes
+		if result != nil && result.announced != nil {
+			go func() {
+				msg.Resp <- <-result.announced
+			}()
+			return
+		}
+		// playback result carries device errors
+		if result != nil {
+			msg.Resp <- result
+			return
+		}
+		msg.Resp <- err
 	case "silence":
 		msg.Resp <- b.Silence()
+	case "alert.expire":
+		doneChan, _ := msg.Data.(chan struct{})
+		if err := b.expireAlert(doneChan); err != nil {
+			log.Printf("Failed to silence alert: %s", err)
+		}
+		msg.Resp <- nil
+	case "alert.turn":
+		if t, ok := msg.Data.(*deviceTurn); ok {
+			b.turnDevice(t)
+		}
+		msg.Resp <- nil
+	case "alert.finished":
+		doneChan, _ := msg.Data.(chan struct{})
+		b.finishAlert(doneChan)
+		msg.Resp <- nil
+	case "alert.announced":
+		a, _ := msg.Data.(*announcement)
+		if a == nil {
+			msg.Resp <- fmt.Errorf("invalid announcement")
+			return
+		}
+		if err := b.announced(a); err != nil {
+			log.Printf("Failed to play announced alert: %s", err)
+		}
+		msg.Resp <- nil
+	case "monitors":
+		msg.Resp <- b.Monitors()
+	case "monitor.add":
+		req, ok := msg.Data.(*monitorRequest)
+		if !ok {
+			msg.Resp <- fmt.Errorf("invalid monitor request")
+			return
+		}
+		// monitor factories might take a while so don't block the message listener
+		go func() {
+			msg.Resp <- b.AddMonitor(req.Name, req.Type, req.Config)
+		}()
+	case "monitor.remove", "monitor.pause", "monitor.resume":
+		name, ok := msg.Data.(string)
+		if !ok {
+			msg.Resp <- fmt.Errorf("invalid monitor name")
+			return
+		}
+		switch msg.Cmd {
+		case "monitor.remove":
+			msg.Resp <- b.RemoveMonitor(name)
+		case "monitor.pause":
+			msg.Resp <- b.PauseMonitor(name)
+		case "monitor.resume":
+			msg.Resp <- b.ResumeMonitor(name)
+		}
 	default:
 		msg.Resp <- fmt.Errorf("invalid command")
 	}
@@ -123,7 +823,7 @@ func (b *Bot) ListenAndAlert() error {
 
 	var wg sync.WaitGroup
 	// Create error channel
-	errChan := make(chan error, len(b.monitors)+1)
+	errChan := make(chan error, 2)
 
 	// Start Bot message listener
 	wg.Add(1)
@@ -150,24 +850,22 @@ func (b *Bot) ListenAndAlert() error {
 		errChan <- b.api.ListenAndServe()
 	}()
 
-	// Start all remote monitors
-	for _, mon := range b.monitors {
-		wg.Add(1)
-		go func(m Monitor) {
-			defer wg.Done()
-			log.Printf("Starting %s", m)
-			errChan <- m.MonitorAndAlert(b.msgChan)
-			log.Printf("%s stopped", m)
-		}(mon)
-	}
-
-	// set bot status to running
+	// Start all registered monitors and set bot status to running
 	b.Lock()
+	b.errChan = make(chan error, 1)
+	for _, e := range b.monitors {
+		b.startMonitor(e)
+	}
 	b.isRunning = true
+	monErrChan := b.errChan
 	b.Unlock()
 
 	// wait for error
-	err := <-errChan
+	var err error
+	select {
+	case err = <-errChan:
+	case err = <-monErrChan:
+	}
 
 	log.Printf("HTTP API service shutting down")
 	if err := b.api.l.Close(); err != nil {
@@ -180,11 +878,13 @@ func (b *Bot) ListenAndAlert() error {
 	log.Printf("Message listener stopped")
 
 	// Stop all remote monitors
-	for _, m := range b.monitors {
-		log.Printf("Shutting down %s", m)
-		m.Stop()
-		log.Printf("%s stopped", m)
+	b.Lock()
+	for _, e := range b.monitors {
+		log.Printf("Shutting down %s", e)
+		e.stop()
 	}
+	b.Unlock()
+	b.wg.Wait()
 	wg.Wait()
 
 	return err
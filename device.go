@@ -0,0 +1,241 @@
+package alertify
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+const (
+	// DeviceGroupAll plays alert on each device of the group in turn.
+	// Spotify Connect plays on a single device per account at a time, so the alert is moved
+	// from one device to the next one once it has played on it for the group Interval.
+	DeviceGroupAll = "all"
+	// DeviceGroupFirst plays alert on the first available device of the group
+	DeviceGroupFirst = "first"
+	// DefaultDeviceInterval is how long the alert plays on each device of DeviceGroupAll group by default
+	DefaultDeviceInterval = 15 * time.Second
+)
+
+// DeviceGroup is a named group of Spotify devices
+type DeviceGroup struct {
+	// Devices contains names or IDs of Spotify devices in the group
+	Devices []string
+	// Mode is either DeviceGroupAll or DeviceGroupFirst.
+	// Empty Mode defaults to DeviceGroupFirst.
+	Mode string
+	// Interval is how long the alert plays on each device of DeviceGroupAll group before
+	// it moves on to the next device. Zero value means DefaultDeviceInterval.
+	Interval time.Duration
+}
+
+// interval returns how long the alert plays on each device of the group
+func (g *DeviceGroup) interval() time.Duration {
+	if g.Interval > 0 {
+		return g.Interval
+	}
+
+	return DefaultDeviceInterval
+}
+
+// validateGroups checks the configuration of device groups
+func validateGroups(groups map[string]*DeviceGroup) error {
+	for name, g := range groups {
+		switch g.Mode {
+		case "", DeviceGroupFirst, DeviceGroupAll:
+		default:
+			return fmt.Errorf("device group %s: invalid mode: %s", name, g.Mode)
+		}
+	}
+
+	return nil
+}
+
+// DeviceResult is the result of playing alert on a device
+type DeviceResult struct {
+	// DeviceID is Spotify device ID
+	DeviceID string `json:"device_id,omitempty"`
+	// Device is Spotify device name
+	Device string `json:"device"`
+	// Error is the playback error
+	Error string `json:"error,omitempty"`
+}
+
+// AlertResult is sent back on Msg.Resp channel once alert has been played
+type AlertResult struct {
+	// Song is the URI of the played song
+	Song string `json:"song"`
+	// Devices contains playback results per device
+	Devices []*DeviceResult `json:"devices"`
+	// announced receives the result of the alert song once the alert announcement finishes
+	announced chan interface{}
+}
+
+// Err returns error if the alert could not be played on any device
+func (r *AlertResult) Err() error {
+	var errs []string
+	for _, d := range r.Devices {
+		if d.Error == "" {
+			return nil
+		}
+		errs = append(errs, d.Device+": "+d.Error)
+	}
+
+	if len(errs) == 0 {
+		return fmt.Errorf("no devices to play %s on", r.Song)
+	}
+
+	return fmt.Errorf("failed to play %s: %s", r.Song, strings.Join(errs, "; "))
+}
+
+// String implements stringer interface
+func (r *AlertResult) String() string {
+	var results []string
+	for _, d := range r.Devices {
+		if d.Error != "" {
+			results = append(results, fmt.Sprintf("failed on %s: %s", d.Device, d.Error))
+			continue
+		}
+		results = append(results, fmt.Sprintf("played on %s", d.Device))
+	}
+
+	return strings.Join(results, ", ")
+}
+
+// newDeviceResult creates device playback result
+func newDeviceResult(device *spotify.PlayerDevice, name string, err error) *DeviceResult {
+	res := &DeviceResult{
+		DeviceID: string(device.ID),
+		Device:   device.Name,
+	}
+	if res.Device == "" {
+		res.Device = name
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	return res
+}
+
+// DeviceGroup returns the device group named group
+func (s *SpotifyClient) DeviceGroup(group string) (*DeviceGroup, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	g, ok := s.groups[group]
+	if !ok {
+		return nil, fmt.Errorf("unknown Spotify device group: %s", group)
+	}
+
+	return g, nil
+}
+
+// PlayGroup plays song on the first available device in the device group named group
+// It returns playback results for every device the song was attempted to be played on.
+// Devices of DeviceGroupAll group which come after them are left to be played on in turn.
+func (s *SpotifyClient) PlayGroup(song *Song, group string) ([]*DeviceResult, error) {
+	g, err := s.DeviceGroup(group)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	defer s.persistToken()
+
+	return s.playDevices(song, g.Devices), nil
+}
+
+// PlayDevice plays song on device with the given name or ID
+func (s *SpotifyClient) PlayDevice(song *Song, device string) *DeviceResult {
+	s.Lock()
+	defer s.Unlock()
+	defer s.persistToken()
+
+	return s.playDevices(song, []string{device})[0]
+}
+
+// playDevices plays song on the first available device with one of the given names or IDs
+// It must be called with client lock held.
+func (s *SpotifyClient) playDevices(song *Song, names []string) []*DeviceResult {
+	var results []*DeviceResult
+	for _, name := range names {
+		name := name
+		device := &spotify.PlayerDevice{}
+		resolve := func() error {
+			devices, err := s.PlayerDevices()
+			if err != nil {
+				return err
+			}
+			d := findDevice(devices, name, name)
+			if d == nil {
+				return fmt.Errorf("Spotify device %s not available", name)
+			}
+			device.ID, device.Name = d.ID, d.Name
+			return nil
+		}
+
+		if err := resolve(); err != nil {
+			results = append(results, newDeviceResult(device, name, err))
+			continue
+		}
+
+		err := s.play(song, device, resolve)
+		results = append(results, newDeviceResult(device, name, err))
+
+		if err == nil {
+			break
+		}
+	}
+
+	return results
+}
+
+// PauseGroup pauses playback on all available devices in the device group named group
+func (s *SpotifyClient) PauseGroup(group string) error {
+	s.Lock()
+	g, ok := s.groups[group]
+	s.Unlock()
+
+	if !ok {
+		return fmt.Errorf("unknown Spotify device group: %s", group)
+	}
+
+	return s.PauseDevices(g.Devices...)
+}
+
+// PauseDevices pauses playback on all available devices with the given names or IDs
+func (s *SpotifyClient) PauseDevices(names ...string) error {
+	s.Lock()
+	defer s.Unlock()
+	defer s.persistToken()
+
+	devices, err := s.PlayerDevices()
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, name := range names {
+		device := findDevice(devices, name, name)
+		if device == nil {
+			continue
+		}
+
+		log.Printf("Attempting to pause alert playback on Device ID: %s Name: %s", device.ID, device.Name)
+
+		if err := s.PauseOpt(&spotify.PlayOptions{DeviceID: &device.ID}); err != nil {
+			errs = append(errs, device.Name+": "+err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to pause devices: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package alertify
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestValidateGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		devices []string
+		wantErr bool
+	}{
+		{name: "default mode", devices: []string{"kitchen", "office"}},
+		{name: "first mode", mode: DeviceGroupFirst, devices: []string{"kitchen", "office"}},
+		{name: "all mode", mode: DeviceGroupAll, devices: []string{"kitchen", "office"}},
+		{name: "invalid mode", mode: "random", devices: []string{"kitchen"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := map[string]*DeviceGroup{"group": {Devices: tt.devices, Mode: tt.mode}}
+			if err := validateGroups(groups); (err != nil) != tt.wantErr {
+				t.Errorf("validateGroups() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeviceGroupInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		want     time.Duration
+	}{
+		{name: "default", want: DefaultDeviceInterval},
+		{name: "negative", interval: -time.Second, want: DefaultDeviceInterval},
+		{name: "configured", interval: time.Minute, want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &DeviceGroup{Interval: tt.interval}
+			if got := g.interval(); got != tt.want {
+				t.Errorf("interval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBotPlayInTurn(t *testing.T) {
+	b := &Bot{
+		Mutex:        &sync.Mutex{},
+		msgChan:      make(chan *Msg),
+		closeMsgChan: make(chan struct{}),
+	}
+	song := &Song{URI: "spotify:track:7yTIKQzqRQfXDKKiPw3GJY"}
+	devices := []string{"kitchen", "office", "garden"}
+
+	doneChan := b.startAlertTasks()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		b.playInTurn(song, devices, time.Millisecond, doneChan)
+	}()
+
+	// the alert moves to the first two devices in order
+	for _, want := range devices[:2] {
+		msg := <-b.msgChan
+		turn, ok := msg.Data.(*deviceTurn)
+		if msg.Cmd != "alert.turn" || !ok {
+			t.Fatalf("unexpected message: %s %v", msg.Cmd, msg.Data)
+		}
+		if turn.device != want || turn.song != song || turn.doneChan != doneChan {
+			t.Errorf("turn = %+v, want device %s", turn, want)
+		}
+	}
+
+	// silenced alert must not wait for the message listener to move it to the remaining devices
+	b.stopAlertTasks()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Errorf("playInTurn did not stop")
+	}
+}
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/milosgajdos/alertify"
 	"github.com/milosgajdos/alertify/monitor"
+	"github.com/milosgajdos/alertify/player"
 )
 
 const (
@@ -25,24 +29,72 @@ var (
 	deviceName string
 	// deviceID is Spotify client device ID
 	deviceID string
+	// fallbackDeviceName is Spotify device name used when the primary device is unavailable
+	fallbackDeviceName string
 	// songURI is Spotify song URI
 	songURI string
+	// shuffle enables shuffle when songURI is album, playlist or artist
+	shuffle bool
+	// randomTrack plays random album or playlist track
+	randomTrack bool
+	// volume is alert volume in percent
+	volume int
+	// volumeRamp is the duration of alert volume ramp
+	volumeRamp time.Duration
+	// positionMs is alert track start position in milliseconds
+	positionMs int
+	// maxDuration is maximum alert play duration
+	maxDuration time.Duration
+	// tokenFile is path to file which stores Spotify OAuth token
+	tokenFile string
+	// fallbackFile is local audio file played when Spotify alert fails
+	fallbackFile string
+	// announcement is text spoken before the alert song
+	announcement string
+	// announceOnly speaks the announcement instead of playing the alert song
+	announceOnly bool
+	// restorePlayback restores interrupted Spotify playback after the alert
+	restorePlayback bool
 	// slackChannel is name of the Slack channel that receives alerts
 	slackChannel string
 	// slackUser is name of the Slack bot which posts alerts to slackChannel
 	slackUser string
 	// slackMsg is a string representing regular expression we are matching on
 	slackMsg string
+	// slackResolve is regular expression matching resolve messages which silence the alert
+	slackResolve string
+	// slackReply replies to matched messages in their threads or with reactions
+	slackReply string
+	// slackSilenceButton posts silence button in reply to alert messages
+	slackSilenceButton bool
+	// slackRules is path to JSON file which contains Slack message rules
+	slackRules string
 )
 
 func init() {
 	flag.StringVar(&redirectURI, "redirect-uri", "http://localhost:8080/callback", "Spotify API redirect URI")
 	flag.StringVar(&deviceName, "device-name", "", "Spotify device name as recognised by Spotify API")
 	flag.StringVar(&deviceID, "device-id", "", "Spotify device ID as recognised by Spotify API")
-	flag.StringVar(&songURI, "song-uri", "spotify:track:2xYlyywNgefLCRDG8hlxZq", "Spotify song URI")
+	flag.StringVar(&fallbackDeviceName, "fallback-device-name", "", "Spotify device name used when the primary device is unavailable")
+	flag.StringVar(&songURI, "song-uri", "spotify:track:2xYlyywNgefLCRDG8hlxZq", "Spotify URI, URL or search expression of a track, album, playlist or artist")
+	flag.BoolVar(&shuffle, "shuffle", false, "Shuffle album, playlist or artist playback")
+	flag.BoolVar(&randomTrack, "random-track", false, "Play random track from album, playlist or artist")
+	flag.IntVar(&volume, "volume", 0, "Alert volume in percent; 0 leaves the device volume unchanged")
+	flag.DurationVar(&volumeRamp, "volume-ramp", 0, "Ramp alert volume up over the given duration")
+	flag.IntVar(&positionMs, "position-ms", 0, "Position in milliseconds to start playing the alert track from")
+	flag.DurationVar(&maxDuration, "max-duration", 0, "Maximum alert play duration; 0 plays the alert until it's silenced")
+	flag.StringVar(&tokenFile, "token-file", "", "Path to file which stores Spotify OAuth token")
+	flag.StringVar(&fallbackFile, "fallback-file", "", "Local audio file played when the alert fails to play on Spotify")
+	flag.StringVar(&announcement, "announcement", "", "Text spoken with espeak before the alert song is played")
+	flag.BoolVar(&announceOnly, "announce-only", false, "Speak the announcement instead of playing the alert song")
+	flag.BoolVar(&restorePlayback, "restore-playback", false, "Restore interrupted Spotify playback after the alert")
 	flag.StringVar(&slackChannel, "slack-channel", "devops-production", "Slack channel that receives alerts")
 	flag.StringVar(&slackUser, "slack-user", "production", "Slack username whose message we alert on")
 	flag.StringVar(&slackMsg, "slack-msg", "alert", "A regexp we are matching the slack messages on")
+	flag.StringVar(&slackResolve, "slack-resolve", "", "A regexp matching the slack messages which silence the alert; its capture group extracts alert fingerprint")
+	flag.StringVar(&slackReply, "slack-reply", "", "Reply to the matched slack messages with the outcome: thread or reaction")
+	flag.BoolVar(&slackSilenceButton, "slack-silence-button", false, "Post Acknowledge / Silence button in reply to the alert messages; requires SLACK_SIGNING_SECRET")
+	flag.StringVar(&slackRules, "slack-rules", "", "Path to JSON file with Slack message rules used instead of -slack-channel, -slack-user and -slack-msg")
 	// disable timestamps and set prefix
 	log.SetFlags(0)
 	log.SetPrefix("[ " + cliname + " ] ")
@@ -54,6 +106,10 @@ type Config struct {
 	Bot *alertify.BotConfig
 	// Slack configures Slack message monitor
 	Slack *monitor.SlackConfig
+	// SlackEvents configures Slack Events API monitor used instead of Slack RTM monitor
+	SlackEvents *monitor.SlackEventsConfig
+	// SlackCommands configures Slack slash commands and interactive buttons
+	SlackCommands *monitor.SlackCommandsConfig
 }
 
 func parseCliFlags() (*Config, error) {
@@ -75,26 +131,159 @@ func parseCliFlags() (*Config, error) {
 		return nil, fmt.Errorf("could not read SLACK_API_KEY environment variable")
 	}
 
+	var rules []*monitor.Rule
+	if slackRules != "" {
+		data, err := ioutil.ReadFile(slackRules)
+		if err != nil {
+			return nil, fmt.Errorf("could not read Slack rules: %s", err)
+		}
+		if err := json.Unmarshal(data, &rules); err != nil {
+			return nil, fmt.Errorf("could not parse Slack rules: %s", err)
+		}
+	}
+
+	var slackEvents *monitor.SlackEventsConfig
+	slackAppToken := os.Getenv("SLACK_APP_TOKEN")
+	slackSigningSecret := os.Getenv("SLACK_SIGNING_SECRET")
+	if slackAppToken != "" || slackSigningSecret != "" {
+		slackEvents = &monitor.SlackEventsConfig{
+			APIKey:        slackAPIKey,
+			AppToken:      slackAppToken,
+			SigningSecret: slackSigningSecret,
+			Channel:       slackChannel,
+			User:          slackUser,
+			Msg:           slackMsg,
+			Resolve:       slackResolve,
+			Rules:         rules,
+			Reply:         slackReply,
+			SilenceButton: slackSilenceButton,
+		}
+	}
+
+	var slackCommands *monitor.SlackCommandsConfig
+	if slackSigningSecret != "" {
+		slackCommands = &monitor.SlackCommandsConfig{SigningSecret: slackSigningSecret}
+	} else if slackSilenceButton {
+		return nil, fmt.Errorf("could not read SLACK_SIGNING_SECRET environment variable")
+	}
+
+	var (
+		players        map[string]alertify.Player
+		fallbackPlayer string
+		local          *player.LocalPlayer
+	)
+	if fallbackFile != "" || announcement != "" {
+		var err error
+		if local, err = player.NewLocalPlayer(&player.LocalConfig{File: fallbackFile}); err != nil {
+			return nil, fmt.Errorf("could not create local player: %s", err)
+		}
+		players = map[string]alertify.Player{"local": local}
+	}
+	if fallbackFile != "" {
+		fallbackPlayer = "local"
+	}
+
+	var (
+		announcer alertify.Announcer
+		routes    []*alertify.Route
+	)
+	if announcement != "" {
+		tts, err := player.NewTTS(&player.TTSConfig{Player: local})
+		if err != nil {
+			return nil, fmt.Errorf("could not create announcer: %s", err)
+		}
+		announcer = tts
+		routes = []*alertify.Route{{
+			Name: "announcement",
+			Song: &alertify.Song{Announcement: announcement, AnnounceOnly: announceOnly},
+		}}
+	}
+
 	return &Config{
 		Bot: &alertify.BotConfig{
+			Players:        players,
+			FallbackPlayer: fallbackPlayer,
+			Announcer:      announcer,
+			Routes:         routes,
+			MonitorTypes:   monitorTypes(slackAPIKey),
 			Spotify: &alertify.SpotifyConfig{
 				ClientID:     spotifyID,
 				ClientSecret: spotifySecret,
 				RedirectURI:  redirectURI,
+				TokenFile:    tokenFile,
 				DeviceName:   deviceName,
 				DeviceID:     deviceID,
 				SongURI:      songURI,
+
+				Shuffle:            shuffle,
+				RandomTrack:        randomTrack,
+				Volume:             volume,
+				VolumeRamp:         volumeRamp,
+				PositionMs:         positionMs,
+				MaxDuration:        maxDuration,
+				FallbackDeviceName: fallbackDeviceName,
+				RestorePlayback:    restorePlayback,
 			},
 		},
 		Slack: &monitor.SlackConfig{
-			APIKey:  slackAPIKey,
-			Channel: slackChannel,
-			User:    slackUser,
-			Msg:     slackMsg,
+			APIKey:        slackAPIKey,
+			Channel:       slackChannel,
+			User:          slackUser,
+			Msg:           slackMsg,
+			Resolve:       slackResolve,
+			Rules:         rules,
+			Reply:         slackReply,
+			SilenceButton: slackSilenceButton,
 		},
+		SlackEvents:   slackEvents,
+		SlackCommands: slackCommands,
 	}, nil
 }
 
+// decodeConfig decodes JSON monitor config into c unless config is empty
+func decodeConfig(config json.RawMessage, c interface{}) error {
+	if len(config) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(config, c)
+}
+
+// monitorTypes returns factories of monitors which can be added via API
+// Slack monitors use slackAPIKey unless their config sets their own API key.
+func monitorTypes(slackAPIKey string) map[string]alertify.MonitorFactory {
+	return map[string]alertify.MonitorFactory{
+		"slack": func(config json.RawMessage) (alertify.Monitor, error) {
+			c := &monitor.SlackConfig{APIKey: slackAPIKey}
+			if err := decodeConfig(config, c); err != nil {
+				return nil, err
+			}
+			return monitor.NewSlackMonitor(c)
+		},
+		"discord": func(config json.RawMessage) (alertify.Monitor, error) {
+			c := &monitor.DiscordConfig{}
+			if err := decodeConfig(config, c); err != nil {
+				return nil, err
+			}
+			return monitor.NewDiscordMonitor(c)
+		},
+		"matrix": func(config json.RawMessage) (alertify.Monitor, error) {
+			c := &monitor.MatrixConfig{}
+			if err := decodeConfig(config, c); err != nil {
+				return nil, err
+			}
+			return monitor.NewMatrixMonitor(c)
+		},
+		"irc": func(config json.RawMessage) (alertify.Monitor, error) {
+			c := &monitor.IRCConfig{}
+			if err := decodeConfig(config, c); err != nil {
+				return nil, err
+			}
+			return monitor.NewIRCMonitor(c)
+		},
+	}
+}
+
 // registerSignals registers signal notification channel
 func registerSignals(sig ...os.Signal) <-chan os.Signal {
 	// Signal handler to stop the bot when termination signal is received
@@ -153,18 +342,36 @@ func main() {
 	}
 
 	// Create Slack monitor
-	slack, err := monitor.NewSlackMonitor(cfg.Slack)
+	var slack alertify.Monitor
+	if cfg.SlackEvents != nil {
+		slack, err = monitor.NewSlackEventsMonitor(cfg.SlackEvents)
+	} else {
+		slack, err = monitor.NewSlackMonitor(cfg.Slack)
+	}
 	if err != nil {
 		log.Printf("Error creating slack monitor: %s", err)
 		os.Exit(1)
 	}
 
 	// register slack message monitor
-	if err := bot.RegisterMonitor(slack); err != nil {
+	if err := bot.RegisterMonitor("slack", slack); err != nil {
 		log.Printf("Error registering %s: %s", slack, err)
 		os.Exit(1)
 	}
 
+	// register Slack commands which control the bot from Slack
+	if cfg.SlackCommands != nil {
+		commands, err := monitor.NewSlackCommands(cfg.SlackCommands)
+		if err != nil {
+			log.Printf("Error creating slack commands: %s", err)
+			os.Exit(1)
+		}
+		if err := bot.RegisterMonitor("slack-commands", commands); err != nil {
+			log.Printf("Error registering %s: %s", commands, err)
+			os.Exit(1)
+		}
+	}
+
 	// start alertify bot
 	if err := listenAndAlert(bot); err != nil {
 		log.Printf("Error: %s", err)
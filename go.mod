@@ -6,14 +6,14 @@ require (
 	github.com/golang/protobuf v1.1.0 // indirect
 	github.com/gorilla/context v1.1.1 // indirect
 	github.com/gorilla/mux v1.6.2
-	github.com/gorilla/websocket v1.2.0 // indirect
+	github.com/gorilla/websocket v1.2.0
 	github.com/lusis/go-slackbot v0.0.0-20180109053408-401027ccfef5 // indirect
 	github.com/lusis/slack-test v0.0.0-20190426140909-c40012f20018 // indirect
 	github.com/nlopes/slack v0.2.0
 	github.com/stretchr/testify v1.6.1 // indirect
 	github.com/zmb3/spotify v0.0.0-20180212041948-79deba8533f6
 	golang.org/x/net v0.0.0-20180524181706-dfa909b99c79 // indirect
-	golang.org/x/oauth2 v0.0.0-20180528195736-8373c646843f // indirect
+	golang.org/x/oauth2 v0.0.0-20180528195736-8373c646843f
 	golang.org/x/sync v0.0.0-20201207232520-09787c993a3a // indirect
 	google.golang.org/appengine v1.0.0 // indirect
 )
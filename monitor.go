@@ -1,5 +1,28 @@
 package alertify
 
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// MonitorRunning means monitor is running
+	MonitorRunning = "running"
+	// MonitorPaused means monitor is running but its messages are ignored
+	MonitorPaused = "paused"
+	// MonitorDegraded means monitor has recently failed and is being restarted
+	MonitorDegraded = "degraded"
+	// MonitorFailed means monitor has failed and won't be restarted
+	MonitorFailed = "failed"
+	// MonitorStopped means monitor is registered but not running
+	MonitorStopped = "stopped"
+)
+
+// monitorStopInterval is the interval of repeated attempts to stop a monitor
+const monitorStopInterval = 100 * time.Millisecond
+
 // Monitor monitors some activity and sends message to channel
 type Monitor interface {
 	// MonitorAndAlert sends messages to message channel
@@ -9,3 +32,214 @@ type Monitor interface {
 	// String implements stringer interface
 	String() string
 }
+
+// MonitorFactory creates a monitor from its JSON configuration
+type MonitorFactory func(config json.RawMessage) (Monitor, error)
+
+// MonitorStatus describes the state of a registered monitor
+type MonitorStatus struct {
+	// Name is a unique name of the monitor
+	Name string `json:"name"`
+	// Monitor is the monitor description
+	Monitor string `json:"monitor"`
+	// State is the monitor state
+	State string `json:"state"`
+	// Restarts is the number of monitor restarts
+	Restarts int `json:"restarts"`
+	// Error is the last monitor error
+	Error string `json:"error,omitempty"`
+}
+
+// monitorEntry keeps track of a monitor registered with Bot
+type monitorEntry struct {
+	// name is a unique monitor name
+	name string
+	// mon is the registered monitor
+	mon Monitor
+	// msgChan receives monitor messages
+	msgChan chan *Msg
+	// doneChan stops monitor message forwarder
+	doneChan chan struct{}
+	// isRunning checks if monitor is running
+	isRunning bool
+	// isPaused checks if monitor messages are ignored
+	isPaused bool
+	// isFailed checks if monitor has failed and won't be restarted
+	isFailed bool
+	// restarts counts monitor restarts
+	restarts int
+	// lastErr is the last monitor error
+	lastErr error
+	// degradedUntil is the time until which monitor is considered degraded
+	degradedUntil time.Time
+	// mutex
+	*sync.Mutex
+}
+
+// newMonitorEntry creates new monitor entry and returns it
+func newMonitorEntry(name string, m Monitor) *monitorEntry {
+	return &monitorEntry{
+		name:     name,
+		mon:      m,
+		msgChan:  make(chan *Msg),
+		doneChan: make(chan struct{}),
+		Mutex:    &sync.Mutex{},
+	}
+}
+
+// String returns monitor entry description
+func (e *monitorEntry) String() string {
+	return fmt.Sprintf("%s (%s)", e.mon, e.name)
+}
+
+// Status returns monitor status
+func (e *monitorEntry) Status() *MonitorStatus {
+	e.Lock()
+	defer e.Unlock()
+
+	state := MonitorStopped
+	switch {
+	case e.isFailed:
+		state = MonitorFailed
+	case e.isRunning && e.isPaused:
+		state = MonitorPaused
+	case e.isRunning && time.Now().Before(e.degradedUntil):
+		state = MonitorDegraded
+	case e.isRunning:
+		state = MonitorRunning
+	}
+
+	status := &MonitorStatus{
+		Name:     e.name,
+		Monitor:  e.mon.String(),
+		State:    state,
+		Restarts: e.restarts,
+	}
+	if e.lastErr != nil {
+		status.Error = e.lastErr.Error()
+	}
+
+	return status
+}
+
+// setPaused pauses or resumes forwarding of monitor messages
+func (e *monitorEntry) setPaused(paused bool) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.isPaused = paused
+}
+
+// paused returns true if monitor messages are ignored
+func (e *monitorEntry) paused() bool {
+	e.Lock()
+	defer e.Unlock()
+
+	return e.isPaused
+}
+
+// forward forwards monitor messages to msgChan unless the monitor is paused
+func (e *monitorEntry) forward(msgChan chan<- *Msg) {
+	for {
+		select {
+		case msg := <-e.msgChan:
+			if e.paused() {
+				reply(msg, fmt.Errorf("monitor %s is paused", e.name))
+				continue
+			}
+			select {
+			case msgChan <- msg:
+			case <-e.doneChan:
+				// monitor waits for response so don't leave it hanging
+				reply(msg, fmt.Errorf("monitor %s stopped", e.name))
+				return
+			}
+		case <-e.doneChan:
+			return
+		}
+	}
+}
+
+// reply responds to msg with err without blocking
+// Monitors wait for responses on buffered channels, so the response is dropped only if nobody waits for it.
+func reply(msg *Msg, err error) {
+	select {
+	case msg.Resp <- err:
+	default:
+	}
+}
+
+// degrade records monitor failure and marks monitor degraded for duration d
+func (e *monitorEntry) degrade(err error, d time.Duration) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.lastErr = err
+	e.restarts++
+	e.degradedUntil = time.Now().Add(d)
+}
+
+// fail records monitor failure and stops its message forwarder
+func (e *monitorEntry) fail(err error) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.lastErr = err
+	e.isFailed = true
+	if e.isRunning {
+		close(e.doneChan)
+		e.isRunning = false
+	}
+}
+
+// stopped returns true if monitor has been stopped
+func (e *monitorEntry) stopped() bool {
+	select {
+	case <-e.doneChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop stops the monitor message forwarder and its supervisor
+// The supervisor stops the monitor once it notices doneChan has been closed.
+func (e *monitorEntry) stop() {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.isRunning {
+		close(e.doneChan)
+		e.isRunning = false
+	}
+}
+
+// run runs the monitor until it returns
+// When doneChan is closed, the monitor is stopped repeatedly until it returns,
+// since Stop has no effect on the monitor which has not started running yet.
+func (e *monitorEntry) run() error {
+	runDone := make(chan struct{})
+	defer close(runDone)
+
+	go func() {
+		select {
+		case <-e.doneChan:
+		case <-runDone:
+			return
+		}
+
+		ticker := time.NewTicker(monitorStopInterval)
+		defer ticker.Stop()
+
+		for {
+			e.mon.Stop()
+			select {
+			case <-ticker.C:
+			case <-runDone:
+				return
+			}
+		}
+	}()
+
+	return e.mon.MonitorAndAlert(e.msgChan)
+}
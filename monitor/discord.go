@@ -0,0 +1,582 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/milosgajdos/alertify"
+)
+
+const (
+	// DefaultDiscordGatewayURL is the default Discord gateway URL
+	DefaultDiscordGatewayURL = "wss://gateway.discord.gg/?v=10&encoding=json"
+	// DefaultDiscordAPIURL is the default Discord REST API URL
+	DefaultDiscordAPIURL = "https://discord.com/api/v10"
+	// discordIntents subscribes to guild and direct messages including their content
+	discordIntents = 1<<9 | 1<<12 | 1<<15
+	// discordReconnectDelay is the initial delay before reconnecting when Discord asks to reconnect
+	discordReconnectDelay = time.Second
+	// discordMaxReconnectDelay is the maximum delay before reconnecting
+	discordMaxReconnectDelay = time.Minute
+	// discordInvalidSessionDelay is the minimum delay before starting new session after invalid session.
+	// Discord asks clients to wait between 1 and 5 seconds.
+	discordInvalidSessionDelay = time.Second
+)
+
+// Discord gateway opcodes
+const (
+	discordDispatch       = 0
+	discordHeartbeat      = 1
+	discordIdentify       = 2
+	discordResume         = 6
+	discordReconnect      = 7
+	discordInvalidSession = 9
+	discordHello          = 10
+	discordHeartbeatACK   = 11
+)
+
+// DiscordConfig configures Discord monitor
+type DiscordConfig struct {
+	// Token is Discord bot token
+	Token string
+	// GatewayURL is Discord gateway URL. It defaults to DefaultDiscordGatewayURL.
+	GatewayURL string
+	// APIURL is Discord REST API URL. It defaults to DefaultDiscordAPIURL.
+	APIURL string
+	// Channel is the name or ID of Discord channel.
+	// Empty Channel matches messages in all channels the bot can read.
+	Channel string
+	// User is the name or ID of Discord user or bot which posts the messages.
+	// Empty User matches messages posted by anyone.
+	User string
+	// Msg is the message we are matching for
+	Msg string
+	// Resolve is the resolve message we are matching for. Resolve messages silence the alert.
+	// Its capture group can extract alert fingerprint; see FingerprintGroup.
+	Resolve string
+	// Rules configure messages the monitor acts upon. The first matching rule is applied.
+	// If there are no rules, messages posted by User in Channel which match Msg play the alert
+	// and the messages which match Resolve silence it.
+	Rules []*Rule
+}
+
+// DiscordMonitor monitors Discord messages received via Discord gateway
+type DiscordMonitor struct {
+	// token is Discord bot token
+	token string
+	// gatewayURL is Discord gateway URL
+	gatewayURL string
+	// user is Discord user name or ID
+	user string
+	// channel is Discord channel name or ID
+	channel string
+	// matcher matches Discord messages
+	matcher *msgMatcher
+	// reconnectDelay is the initial delay before reconnecting when Discord asks to reconnect
+	reconnectDelay time.Duration
+	// invalidSessionDelay is the minimum delay before starting new session after invalid session
+	invalidSessionDelay time.Duration
+	// doneChan stops the monitor
+	doneChan chan struct{}
+	// isRunning checks if monitor is running
+	isRunning bool
+	// mutex
+	*sync.Mutex
+}
+
+// gatewayPayload is Discord gateway message
+type gatewayPayload struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d,omitempty"`
+	S  *int64          `json:"s,omitempty"`
+	T  string          `json:"t,omitempty"`
+}
+
+// gatewaySession is Discord gateway session which can be resumed after reconnecting
+type gatewaySession struct {
+	// id is the session ID. Empty ID means there is no session to resume.
+	id string
+	// resumeURL is the gateway URL the session is resumed on
+	resumeURL string
+	// seq is the last received event sequence number; -1 means none
+	seq int64
+	// ready is true once the session has been started or resumed
+	ready bool
+}
+
+// discordMessage is Discord MESSAGE_CREATE event
+type discordMessage struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	Content   string `json:"content"`
+	Author    struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"author"`
+	Embeds []struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+	} `json:"embeds"`
+}
+
+// discordNames looks up Discord channel names via Discord REST API
+type discordNames struct {
+	// url is Discord REST API URL
+	url string
+	// token is Discord bot token
+	token string
+	// client sends API requests
+	client *http.Client
+}
+
+// userName returns empty name as Discord messages carry their author names
+func (n *discordNames) userName(id string) (string, error) {
+	return "", nil
+}
+
+// botName returns empty name as Discord bots are users
+func (n *discordNames) botName(id string) (string, error) {
+	return "", nil
+}
+
+// identity returns no IDs as Discord monitor doesn't post any messages
+func (n *discordNames) identity() (string, string, error) {
+	return "", "", nil
+}
+
+// channelName returns the name of channel with the given ID
+func (n *discordNames) channelName(id string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, n.url+"/channels/"+id, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bot "+n.token)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Discord API request failed: %s", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		// restarting the monitor won't fix bad credentials
+		return "", alertify.Fatal(fmt.Errorf("invalid Discord token"))
+	default:
+		return "", fmt.Errorf("Discord API request failed: %s", resp.Status)
+	}
+
+	var channel struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&channel); err != nil {
+		return "", fmt.Errorf("invalid Discord API response: %s", err)
+	}
+
+	return channel.Name, nil
+}
+
+// NewDiscordMonitor creates new Discord message monitor
+func NewDiscordMonitor(c *DiscordConfig) (*DiscordMonitor, error) {
+	if c.Token == "" {
+		return nil, fmt.Errorf("missing Discord token")
+	}
+
+	gatewayURL := c.GatewayURL
+	if gatewayURL == "" {
+		gatewayURL = DefaultDiscordGatewayURL
+	}
+
+	apiURL := c.APIURL
+	if apiURL == "" {
+		apiURL = DefaultDiscordAPIURL
+	}
+
+	names := &discordNames{
+		url:    strings.TrimSuffix(apiURL, "/"),
+		token:  c.Token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	matcher, err := newMsgMatcher("Discord", c.Rules, c.Channel, c.User, c.Msg, c.Resolve, newNameCache(names))
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiscordMonitor{
+		token:               c.Token,
+		gatewayURL:          gatewayURL,
+		user:                c.User,
+		channel:             c.Channel,
+		matcher:             matcher,
+		reconnectDelay:      discordReconnectDelay,
+		invalidSessionDelay: discordInvalidSessionDelay,
+		Mutex:               &sync.Mutex{},
+	}, nil
+}
+
+// String returns the name of the monitor
+func (d *DiscordMonitor) String() string {
+	return "Discord Monitor"
+}
+
+// Channel returns the name of the Discord channel which we monitor
+func (d *DiscordMonitor) Channel() string {
+	return d.channel
+}
+
+// User returns Discord user name whose message we monitor
+func (d *DiscordMonitor) User() string {
+	return d.user
+}
+
+// watchGateway receives Discord messages via gateway until doneChan is closed
+// It reconnects with growing delay when Discord asks the client to reconnect and resumes
+// the session unless Discord has invalidated it.
+func (d *DiscordMonitor) watchGateway(eventChan chan *msgEvent, errChan chan error, doneChan chan struct{}) {
+	s := &gatewaySession{seq: -1}
+	delay := d.reconnectDelay
+
+	for {
+		s.ready = false
+		op, err := d.session(s, eventChan, doneChan)
+		select {
+		case <-doneChan:
+			return
+		default:
+		}
+
+		if err != nil {
+			d.sendErr(errChan, doneChan, err)
+			return
+		}
+
+		// delay only grows while reconnects fail to start the session
+		if s.ready {
+			delay = d.reconnectDelay
+		}
+		wait := delay
+		if delay *= 2; delay > discordMaxReconnectDelay {
+			delay = discordMaxReconnectDelay
+		}
+		if op == discordInvalidSession {
+			jitter := time.Duration(rand.Int63n(4*int64(d.invalidSessionDelay) + 1))
+			if d.invalidSessionDelay+jitter > wait {
+				wait = d.invalidSessionDelay + jitter
+			}
+		}
+
+		if s.id != "" {
+			log.Printf("Resuming Discord gateway session in %s", wait)
+		} else {
+			log.Printf("Reconnecting to Discord gateway in %s", wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-doneChan:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// session opens Discord gateway connection and receives messages until Discord asks to reconnect
+// It resumes session s if it has been started before and returns the opcode which ended it.
+func (d *DiscordMonitor) session(s *gatewaySession, eventChan chan *msgEvent, doneChan chan struct{}) (int, error) {
+	gatewayURL := d.gatewayURL
+	if s.id != "" && s.resumeURL != "" {
+		gatewayURL = s.resumeURL
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(gatewayURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to Discord gateway: %s", err)
+	}
+
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	go func() {
+		select {
+		case <-doneChan:
+		case <-closeChan:
+		}
+		conn.Close()
+	}()
+
+	hello := new(gatewayPayload)
+	if err := conn.ReadJSON(hello); err != nil {
+		return 0, fmt.Errorf("failed to read Discord gateway hello: %s", err)
+	}
+	if hello.Op != discordHello {
+		return 0, fmt.Errorf("unexpected Discord gateway opcode: %d", hello.Op)
+	}
+
+	var h struct {
+		HeartbeatInterval int64 `json:"heartbeat_interval"`
+	}
+	if err := json.Unmarshal(hello.D, &h); err != nil || h.HeartbeatInterval <= 0 {
+		return 0, fmt.Errorf("invalid Discord gateway hello")
+	}
+
+	// gateway connection supports only one concurrent writer
+	writeMutex := &sync.Mutex{}
+	send := func(op int, data interface{}) error {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		return conn.WriteJSON(map[string]interface{}{"op": op, "d": data})
+	}
+
+	if s.id != "" {
+		// gateway replays the events missed since seq
+		if err := send(discordResume, map[string]interface{}{
+			"token":      d.token,
+			"session_id": s.id,
+			"seq":        s.seq,
+		}); err != nil {
+			return 0, fmt.Errorf("failed to resume Discord gateway session: %s", err)
+		}
+	} else if err := send(discordIdentify, map[string]interface{}{
+		"token":   d.token,
+		"intents": discordIntents,
+		"properties": map[string]string{
+			"os":      "linux",
+			"browser": "alertify",
+			"device":  "alertify",
+		},
+	}); err != nil {
+		return 0, fmt.Errorf("failed to identify with Discord gateway: %s", err)
+	}
+
+	// seq is the last received event sequence number; -1 means none
+	seq := s.seq
+	defer func() {
+		s.seq = atomic.LoadInt64(&seq)
+	}()
+	heartbeat := func() error {
+		var s interface{}
+		if last := atomic.LoadInt64(&seq); last >= 0 {
+			s = last
+		}
+		return send(discordHeartbeat, s)
+	}
+
+	// acked is 1 if the last heartbeat has been acknowledged
+	acked := int32(1)
+	go func() {
+		ticker := time.NewTicker(time.Duration(h.HeartbeatInterval) * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				// zombied connection must be closed and reconnected
+				if !atomic.CompareAndSwapInt32(&acked, 1, 0) {
+					log.Printf("Discord gateway heartbeat not acknowledged")
+					conn.Close()
+					return
+				}
+				if err := heartbeat(); err != nil {
+					return
+				}
+			case <-closeChan:
+				return
+			}
+		}
+	}()
+
+	for {
+		payload := new(gatewayPayload)
+		if err := conn.ReadJSON(payload); err != nil {
+			return 0, discordErr(err)
+		}
+
+		if payload.S != nil {
+			atomic.StoreInt64(&seq, *payload.S)
+		}
+
+		switch payload.Op {
+		case discordDispatch:
+			switch payload.T {
+			case "READY":
+				var ready struct {
+					SessionID        string `json:"session_id"`
+					ResumeGatewayURL string `json:"resume_gateway_url"`
+				}
+				if err := json.Unmarshal(payload.D, &ready); err != nil {
+					log.Printf("Invalid Discord gateway READY event: %s", err)
+				}
+				s.id, s.resumeURL = ready.SessionID, resumeURL(ready.ResumeGatewayURL, d.gatewayURL)
+				s.ready = true
+				log.Printf("Connected to Discord gateway")
+			case "RESUMED":
+				s.ready = true
+				log.Printf("Resumed Discord gateway session")
+			case "MESSAGE_CREATE":
+				msg := new(discordMessage)
+				if err := json.Unmarshal(payload.D, msg); err != nil {
+					log.Printf("Invalid Discord message: %s", err)
+					continue
+				}
+				select {
+				case eventChan <- newDiscordEvent(msg):
+				case <-doneChan:
+					return 0, nil
+				}
+			}
+		case discordHeartbeat:
+			if err := heartbeat(); err != nil {
+				return 0, discordErr(err)
+			}
+		case discordHeartbeatACK:
+			atomic.StoreInt32(&acked, 1)
+		case discordReconnect:
+			return payload.Op, nil
+		case discordInvalidSession:
+			// session which can't be resumed must be started anew
+			var resumable bool
+			if err := json.Unmarshal(payload.D, &resumable); err != nil || !resumable {
+				s.id, s.resumeURL = "", ""
+				atomic.StoreInt64(&seq, -1)
+			}
+			return payload.Op, nil
+		}
+	}
+}
+
+// resumeURL returns the URL of resume gateway with the query of gatewayURL, e.g. API version and encoding
+func resumeURL(resumeGatewayURL, gatewayURL string) string {
+	if resumeGatewayURL == "" {
+		return ""
+	}
+
+	u, err := url.Parse(gatewayURL)
+	if err != nil || u.RawQuery == "" {
+		return resumeGatewayURL
+	}
+
+	return strings.TrimSuffix(resumeGatewayURL, "/") + "/?" + u.RawQuery
+}
+
+// newDiscordEvent converts Discord message to message event
+// Alerting bots and webhooks often post embeds only, so embed titles and descriptions
+// are appended to the message text on separate lines.
+func newDiscordEvent(msg *discordMessage) *msgEvent {
+	var lines []string
+	if msg.Content != "" {
+		lines = append(lines, msg.Content)
+	}
+	for _, embed := range msg.Embeds {
+		for _, text := range []string{embed.Title, embed.Description} {
+			if text != "" {
+				lines = append(lines, text)
+			}
+		}
+	}
+
+	return &msgEvent{
+		Type:     "message",
+		Channel:  msg.ChannelID,
+		User:     msg.Author.ID,
+		Username: msg.Author.Username,
+		Text:     strings.Join(lines, "\n"),
+		TS:       msg.ID,
+	}
+}
+
+// discordErr returns Discord gateway connection error
+// Errors caused by invalid credentials or intents are fatal.
+func discordErr(err error) error {
+	if ce, ok := err.(*websocket.CloseError); ok {
+		switch ce.Code {
+		case 4004:
+			return alertify.Fatal(fmt.Errorf("invalid Discord token"))
+		case 4013, 4014:
+			return alertify.Fatal(fmt.Errorf("invalid Discord gateway intents: %s", ce.Text))
+		}
+	}
+
+	return fmt.Errorf("Discord gateway connection failed: %s", err)
+}
+
+// sendErr sends err to errChan unless doneChan is closed
+func (d *DiscordMonitor) sendErr(errChan chan error, doneChan chan struct{}, err error) {
+	select {
+	case errChan <- err:
+	case <-doneChan:
+	}
+}
+
+// MonitorAndAlert monitors Discord messages and notifies alertify Bot when the preconfigured message regexp is matched
+// Every call opens a new gateway connection, so the monitor can be restarted after it fails.
+func (d *DiscordMonitor) MonitorAndAlert(msgChan chan<- *alertify.Msg) error {
+	doneChan := make(chan struct{})
+	d.Lock()
+	d.doneChan = doneChan
+	d.isRunning = true
+	d.Unlock()
+
+	// eventChan receives Discord message events
+	eventChan := make(chan *msgEvent, 16)
+	// errChan is error channel
+	errChan := make(chan error)
+	// watchDone stops gateway watcher when this run finishes
+	watchDone := make(chan struct{})
+	defer close(watchDone)
+
+	go d.watchGateway(eventChan, errChan, watchDone)
+
+	for {
+		select {
+		case ev := <-eventChan:
+			match, err := d.matcher.match(ev)
+			if err != nil {
+				log.Printf("Could not match Discord message: %v", err)
+				if alertify.IsFatal(err) {
+					d.stopped()
+					return err
+				}
+			}
+			if match == nil {
+				continue
+			}
+			// send message to alertify bot to play or silence song
+			d.matcher.act(msgChan, match)
+		case <-doneChan:
+			return nil
+		case err := <-errChan:
+			d.stopped()
+			return err
+		}
+	}
+}
+
+// stopped marks the monitor as not running
+func (d *DiscordMonitor) stopped() {
+	d.Lock()
+	defer d.Unlock()
+
+	d.isRunning = false
+}
+
+// Stop stops Discord monitor
+func (d *DiscordMonitor) Stop() {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.isRunning {
+		close(d.doneChan)
+		d.isRunning = false
+	}
+}
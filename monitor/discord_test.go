@@ -0,0 +1,476 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/milosgajdos/alertify"
+)
+
+const testDiscordToken = "MTA0NjQ2.GvVbCk.discord-bot-token"
+
+// fakeDiscord is fake Discord gateway and REST API
+type fakeDiscord struct {
+	// srv serves Discord gateway and REST API
+	srv *httptest.Server
+	// sessions are frames sent over subsequent gateway connections after READY or RESUMED
+	sessions [][]string
+	// conns counts gateway connections
+	conns int
+	// paths receives URL paths of gateway connections
+	paths chan string
+	// frames receives gateway payloads sent by the client
+	frames chan *gatewayPayload
+}
+
+// newFakeDiscord starts fake Discord and returns it
+func newFakeDiscord(t *testing.T, sessions ...[]string) *fakeDiscord {
+	f := &fakeDiscord{
+		sessions: sessions,
+		paths:    make(chan string, 16),
+		frames:   make(chan *gatewayPayload, 16),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gateway", f.serveGateway)
+	mux.HandleFunc("/resume", f.serveGateway)
+	mux.HandleFunc("/api/channels/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bot "+testDiscordToken {
+			http.Error(w, `{"message": "401: Unauthorized", "code": 0}`, http.StatusUnauthorized)
+			return
+		}
+		names := map[string]string{"1046": "alerts", "1047": "random"}
+		name, ok := names[strings.TrimPrefix(r.URL.Path, "/api/channels/")]
+		if !ok {
+			http.Error(w, `{"message": "Unknown Channel", "code": 10003}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"id":%q,"name":%q}`, strings.TrimPrefix(r.URL.Path, "/api/channels/"), name)
+	})
+
+	f.srv = httptest.NewServer(mux)
+	t.Cleanup(f.srv.Close)
+
+	return f
+}
+
+// gatewayURL returns URL of fake Discord gateway
+func (f *fakeDiscord) gatewayURL() string {
+	return "ws" + strings.TrimPrefix(f.srv.URL, "http") + "/gateway"
+}
+
+// serveGateway says hello, waits for identify or resume and sends frames of the next session
+// Identified sessions start with READY event, resumed sessions with RESUMED event.
+// Frames formatted as close:<code> close the connection with the code.
+func (f *fakeDiscord) serveGateway(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// connections are served by the only client one after another
+	var frames []string
+	if f.conns < len(f.sessions) {
+		frames = f.sessions[f.conns]
+	}
+	f.conns++
+	f.paths <- r.URL.Path
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"op":10,"d":{"heartbeat_interval":45000}}`)); err != nil {
+		return
+	}
+
+	identify := new(gatewayPayload)
+	if err := conn.ReadJSON(identify); err != nil {
+		return
+	}
+	f.frames <- identify
+
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		for {
+			payload := new(gatewayPayload)
+			if err := conn.ReadJSON(payload); err != nil {
+				return
+			}
+			f.frames <- payload
+		}
+	}()
+
+	start := fmt.Sprintf(`{"op":0,"s":1,"t":"READY","d":{"v":10,"session_id":"d1b2c3","resume_gateway_url":%q}}`,
+		"ws"+strings.TrimPrefix(f.srv.URL, "http")+"/resume")
+	if identify.Op == discordResume {
+		start = `{"op":0,"s":null,"t":"RESUMED","d":null}`
+	}
+	frames = append([]string{start}, frames...)
+	for _, frame := range frames {
+		var code int
+		if _, err := fmt.Sscanf(frame, "close:%d", &code); err == nil {
+			msg := websocket.FormatCloseMessage(code, "closed by fake Discord")
+			if err := conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second)); err != nil {
+				return
+			}
+			<-readDone
+			return
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+			return
+		}
+	}
+
+	// keep the connection open until the client closes it
+	<-readDone
+}
+
+// receivePath returns URL path of the next gateway connection
+func (f *fakeDiscord) receivePath(t *testing.T) string {
+	t.Helper()
+
+	select {
+	case path := <-f.paths:
+		return path
+	case <-time.After(5 * time.Second):
+		t.Fatal("gateway connection not received")
+		return ""
+	}
+}
+
+// receiveFrame returns the next gateway payload sent by the client
+func (f *fakeDiscord) receiveFrame(t *testing.T) *gatewayPayload {
+	t.Helper()
+
+	select {
+	case payload := <-f.frames:
+		return payload
+	case <-time.After(5 * time.Second):
+		t.Fatal("gateway payload not received")
+		return nil
+	}
+}
+
+// messageCreate returns MESSAGE_CREATE dispatch with sequence number seq and message msg
+func messageCreate(seq int, msg string) string {
+	return fmt.Sprintf(`{"op":0,"s":%d,"t":"MESSAGE_CREATE","d":%s}`, seq, msg)
+}
+
+func TestDiscordMonitor(t *testing.T) {
+	f := newFakeDiscord(t,
+		[]string{
+			// other channel
+			messageCreate(2, `{"id":"2001","channel_id":"1047","content":"ALERT DiskFull","author":{"id":"3001","username":"nagios"}}`),
+			// other author
+			messageCreate(3, `{"id":"2002","channel_id":"1046","content":"ALERT DiskFull","author":{"id":"3002","username":"bob"}}`),
+			messageCreate(4, `{"id":"2003","channel_id":"1046","content":"ALERT DiskFull","author":{"id":"3001","username":"nagios"}}`),
+			`{"op":1,"d":null}`,
+			`{"op":7,"d":null}`,
+		},
+		[]string{
+			// alerting bots often post embeds only
+			messageCreate(5, `{"id":"2004","channel_id":"1046","content":"","author":{"id":"3001","username":"nagios"},`+
+				`"embeds":[{"title":"RESOLVED DiskFull","description":"db1 has enough space"}]}`),
+			`{"op":9,"d":false}`,
+		},
+		[]string{
+			messageCreate(2, `{"id":"2005","channel_id":"1046","content":"ALERT HighLoad","author":{"id":"3001","username":"nagios"}}`),
+			`{"op":9,"d":true}`,
+		},
+	)
+
+	m, err := NewDiscordMonitor(&DiscordConfig{
+		Token:      testDiscordToken,
+		GatewayURL: f.gatewayURL(),
+		APIURL:     f.srv.URL + "/api/",
+		Channel:    "#alerts",
+		User:       "nagios",
+		Msg:        "^ALERT",
+		Resolve:    "^RESOLVED",
+	})
+	if err != nil {
+		t.Fatalf("failed to create monitor: %v", err)
+	}
+	m.reconnectDelay = time.Millisecond
+	m.invalidSessionDelay = time.Millisecond
+
+	msgChan := make(chan *alertify.Msg)
+	errChan := runMonitor(m, msgChan)
+
+	if path := f.receivePath(t); path != "/gateway" {
+		t.Errorf("connected to %s, want /gateway", path)
+	}
+	identify := f.receiveFrame(t)
+	if identify.Op != discordIdentify {
+		t.Fatalf("opcode = %d, want identify", identify.Op)
+	}
+	var data struct {
+		Token   string `json:"token"`
+		Intents int    `json:"intents"`
+	}
+	if err := json.Unmarshal(identify.D, &data); err != nil {
+		t.Fatalf("invalid identify: %v", err)
+	}
+	if data.Token != testDiscordToken || data.Intents != discordIntents {
+		t.Errorf("identified with token %q and intents %d", data.Token, data.Intents)
+	}
+
+	msg := receiveCommand(t, msgChan)
+	if msg.Cmd != ActionAlert {
+		t.Fatalf("command = %s, want %s", msg.Cmd, ActionAlert)
+	}
+	msg.Resp <- nil
+
+	// heartbeat requested by the gateway carries the last sequence number
+	heartbeat := f.receiveFrame(t)
+	if heartbeat.Op != discordHeartbeat || string(heartbeat.D) != "4" {
+		t.Errorf("heartbeat = %d %s, want %d 4", heartbeat.Op, heartbeat.D, discordHeartbeat)
+	}
+
+	// the monitor resumes the session on resume gateway after it's asked to reconnect
+	expectResume(t, f, 4)
+
+	msg = receiveCommand(t, msgChan)
+	if msg.Cmd != ActionSilence {
+		t.Fatalf("command = %s, want %s", msg.Cmd, ActionSilence)
+	}
+	msg.Resp <- nil
+
+	// invalid session which can't be resumed is started anew
+	if path := f.receivePath(t); path != "/gateway" {
+		t.Errorf("connected to %s, want /gateway", path)
+	}
+	if identify := f.receiveFrame(t); identify.Op != discordIdentify {
+		t.Errorf("opcode = %d, want identify", identify.Op)
+	}
+
+	msg = receiveCommand(t, msgChan)
+	if msg.Cmd != ActionAlert {
+		t.Fatalf("command = %s, want %s", msg.Cmd, ActionAlert)
+	}
+	msg.Resp <- nil
+
+	// resumable invalid session is resumed
+	expectResume(t, f, 2)
+
+	expectNoCommand(t, msgChan, 100*time.Millisecond)
+
+	if err := stopMonitor(t, m, errChan); err != nil {
+		t.Errorf("MonitorAndAlert() error = %v", err)
+	}
+}
+
+// expectResume checks the client resumes Discord session on resume gateway from sequence number seq
+func expectResume(t *testing.T, f *fakeDiscord, seq int64) {
+	t.Helper()
+
+	if path := f.receivePath(t); path != "/resume" {
+		t.Errorf("connected to %s, want /resume", path)
+	}
+
+	resume := f.receiveFrame(t)
+	if resume.Op != discordResume {
+		t.Fatalf("opcode = %d, want resume", resume.Op)
+	}
+
+	var data struct {
+		Token     string `json:"token"`
+		SessionID string `json:"session_id"`
+		Seq       int64  `json:"seq"`
+	}
+	if err := json.Unmarshal(resume.D, &data); err != nil {
+		t.Fatalf("invalid resume: %v", err)
+	}
+	if data.Token != testDiscordToken || data.SessionID != "d1b2c3" || data.Seq != seq {
+		t.Errorf("resumed session %q from %d with token %q, want session d1b2c3 from %d", data.SessionID, data.Seq, data.Token, seq)
+	}
+}
+
+func TestDiscordMonitorRules(t *testing.T) {
+	f := newFakeDiscord(t, []string{
+		messageCreate(2, `{"id":"2001","channel_id":"1047","content":"DOWN api","author":{"id":"3001","username":"nagios"}}`),
+		messageCreate(3, `{"id":"2002","channel_id":"1046","content":"DOWN api","author":{"id":"3001","username":"nagios"}}`),
+	})
+
+	m, err := NewDiscordMonitor(&DiscordConfig{
+		Token:      testDiscordToken,
+		GatewayURL: f.gatewayURL(),
+		APIURL:     f.srv.URL + "/api",
+		Rules: []*Rule{{
+			Name:     "down",
+			Channels: []string{"alerts"},
+			Include:  `^DOWN (?P<service>\w+)`,
+			Song:     &alertify.Song{URI: "spotify:track:7yTIKQzqRQfXDKKiPw3GJY"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create monitor: %v", err)
+	}
+
+	msgChan := make(chan *alertify.Msg)
+	errChan := runMonitor(m, msgChan)
+
+	msg := receiveCommand(t, msgChan)
+	alert, ok := msg.Data.(*alertify.Alert)
+	if msg.Cmd != ActionAlert || !ok {
+		t.Fatalf("command = %s %v, want %s", msg.Cmd, msg.Data, ActionAlert)
+	}
+	if alert.Labels["service"] != "api" || alert.Song == nil || alert.Song.URI != "spotify:track:7yTIKQzqRQfXDKKiPw3GJY" {
+		t.Errorf("alert labels %v and song %v", alert.Labels, alert.Song)
+	}
+	msg.Resp <- nil
+
+	expectNoCommand(t, msgChan, 100*time.Millisecond)
+
+	if err := stopMonitor(t, m, errChan); err != nil {
+		t.Errorf("MonitorAndAlert() error = %v", err)
+	}
+}
+
+func TestDiscordMonitorErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		frames    []string
+		token     string
+		wantFatal bool
+	}{
+		{
+			name:      "authentication failed",
+			frames:    []string{"close:4004"},
+			wantFatal: true,
+		},
+		{
+			name:      "disallowed intents",
+			frames:    []string{"close:4014"},
+			wantFatal: true,
+		},
+		{
+			name:   "session timed out",
+			frames: []string{"close:4009"},
+		},
+		{
+			name:      "channel lookup unauthorized",
+			frames:    []string{messageCreate(2, `{"id":"2001","channel_id":"1046","content":"ALERT DiskFull","author":{"id":"3001","username":"nagios"}}`)},
+			token:     "invalid",
+			wantFatal: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDiscord(t, tt.frames)
+
+			token := tt.token
+			if token == "" {
+				token = testDiscordToken
+			}
+
+			m, err := NewDiscordMonitor(&DiscordConfig{
+				Token:      token,
+				GatewayURL: f.gatewayURL(),
+				APIURL:     f.srv.URL + "/api",
+				Channel:    "alerts",
+				Msg:        "^ALERT",
+			})
+			if err != nil {
+				t.Fatalf("failed to create monitor: %v", err)
+			}
+
+			select {
+			case err := <-runMonitor(m, make(chan *alertify.Msg)):
+				if err == nil || alertify.IsFatal(err) != tt.wantFatal {
+					t.Errorf("MonitorAndAlert() error = %v, want fatal %v", err, tt.wantFatal)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("monitor did not fail")
+			}
+		})
+	}
+}
+
+func TestNewDiscordEvent(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{
+			name: "content",
+			msg:  `{"content":"ALERT DiskFull"}`,
+			want: "ALERT DiskFull",
+		},
+		{
+			name: "embeds",
+			msg:  `{"embeds":[{"title":"ALERT DiskFull","description":"db1 is running out of space"},{"title":"ALERT HighLoad"}]}`,
+			want: "ALERT DiskFull\ndb1 is running out of space\nALERT HighLoad",
+		},
+		{
+			name: "content and embed",
+			msg:  `{"content":"@here","embeds":[{"description":"ALERT DiskFull"}]}`,
+			want: "@here\nALERT DiskFull",
+		},
+		{
+			name: "empty",
+			msg:  `{"embeds":[{"url":"https://grafana.example.com"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := new(discordMessage)
+			if err := json.Unmarshal([]byte(tt.msg), msg); err != nil {
+				t.Fatalf("invalid message: %v", err)
+			}
+
+			if got := newDiscordEvent(msg).Text; got != tt.want {
+				t.Errorf("text = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResumeURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		resumeURL  string
+		gatewayURL string
+		want       string
+	}{
+		{
+			name:       "gateway query",
+			resumeURL:  "wss://gateway-us-east1-b.discord.gg",
+			gatewayURL: DefaultDiscordGatewayURL,
+			want:       "wss://gateway-us-east1-b.discord.gg/?v=10&encoding=json",
+		},
+		{
+			name:       "trailing slash",
+			resumeURL:  "wss://gateway-us-east1-b.discord.gg/",
+			gatewayURL: DefaultDiscordGatewayURL,
+			want:       "wss://gateway-us-east1-b.discord.gg/?v=10&encoding=json",
+		},
+		{
+			name:       "no gateway query",
+			resumeURL:  "wss://gateway-us-east1-b.discord.gg",
+			gatewayURL: "wss://gateway.discord.gg",
+			want:       "wss://gateway-us-east1-b.discord.gg",
+		},
+		{
+			name:       "no resume gateway",
+			gatewayURL: DefaultDiscordGatewayURL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resumeURL(tt.resumeURL, tt.gatewayURL); got != tt.want {
+				t.Errorf("resumeURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,466 @@
+package monitor
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/milosgajdos/alertify"
+)
+
+const (
+	// DefaultIRCReconnectDelay is the default delay between IRC reconnects
+	DefaultIRCReconnectDelay = 10 * time.Second
+	// ircDialTimeout is IRC server connection timeout
+	ircDialTimeout = 30 * time.Second
+	// ircReadTimeout closes IRC connections which receive no messages.
+	// IRC servers ping their clients every few minutes, so silent connections are dead.
+	ircReadTimeout = 5 * time.Minute
+	// ircSASLChunk is the maximum length of SASL AUTHENTICATE message payload
+	ircSASLChunk = 400
+)
+
+// IRCConfig configures IRC monitor
+type IRCConfig struct {
+	// Server is IRC server address, e.g. irc.libera.chat:6697
+	Server string
+	// TLS connects to Server over TLS
+	TLS bool
+	// TLSConfig configures TLS connection. It defaults to verifying Server certificate.
+	TLSConfig *tls.Config
+	// Password is IRC server password
+	Password string
+	// Nick is IRC nick of the monitor
+	Nick string
+	// SASLUser is SASL PLAIN account name. SASL authentication is skipped if it's empty.
+	SASLUser string
+	// SASLPassword is SASL PLAIN account password
+	SASLPassword string
+	// Channels are IRC channels the monitor joins, e.g. #nagios
+	Channels []string
+	// Sender is IRC nick which posts the messages.
+	// Empty Sender matches messages posted by anyone.
+	Sender string
+	// Msg is the message we are matching for
+	Msg string
+	// Resolve is the resolve message we are matching for. Resolve messages silence the alert.
+	// Its capture group can extract alert fingerprint; see FingerprintGroup.
+	Resolve string
+	// ReconnectDelay is the delay before reconnecting dropped connection.
+	// It defaults to DefaultIRCReconnectDelay.
+	ReconnectDelay time.Duration
+}
+
+// IRCMonitor monitors messages posted in IRC channels
+type IRCMonitor struct {
+	// server is IRC server address
+	server string
+	// tlsConfig configures TLS connection; nil tlsConfig disables TLS
+	tlsConfig *tls.Config
+	// password is IRC server password
+	password string
+	// nick is IRC nick of the monitor
+	nick string
+	// saslUser is SASL account name
+	saslUser string
+	// saslPassword is SASL account password
+	saslPassword string
+	// channels are IRC channels the monitor joins
+	channels []string
+	// sender is IRC nick which posts the messages
+	sender string
+	// matcher matches IRC messages
+	matcher *msgMatcher
+	// reconnectDelay is the delay between reconnects
+	reconnectDelay time.Duration
+	// doneChan stops the monitor
+	doneChan chan struct{}
+	// isRunning checks if monitor is running
+	isRunning bool
+	// mutex
+	*sync.Mutex
+}
+
+// ircMessage is IRC protocol message
+type ircMessage struct {
+	// Prefix is message source, e.g. nick!user@host
+	Prefix string
+	// Command is IRC command or numeric reply
+	Command string
+	// Params are command parameters including the trailing one
+	Params []string
+}
+
+// parseIRCMessage parses IRC protocol line and returns it
+// Message tags are ignored.
+func parseIRCMessage(line string) *ircMessage {
+	line = strings.TrimRight(line, "\r\n")
+	msg := new(ircMessage)
+
+	if strings.HasPrefix(line, "@") {
+		if i := strings.Index(line, " "); i >= 0 {
+			line = strings.TrimLeft(line[i+1:], " ")
+		} else {
+			line = ""
+		}
+	}
+
+	if strings.HasPrefix(line, ":") {
+		i := strings.Index(line, " ")
+		if i < 0 {
+			msg.Prefix = line[1:]
+			return msg
+		}
+		msg.Prefix, line = line[1:i], strings.TrimLeft(line[i+1:], " ")
+	}
+
+	var trailing string
+	hasTrailing := false
+	if i := strings.Index(line, " :"); i >= 0 {
+		line, trailing, hasTrailing = line[:i], line[i+2:], true
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) > 0 {
+		msg.Command, msg.Params = strings.ToUpper(fields[0]), fields[1:]
+	}
+	if hasTrailing {
+		msg.Params = append(msg.Params, trailing)
+	}
+
+	return msg
+}
+
+// param returns i-th message parameter or empty string if it doesn't exist
+func (m *ircMessage) param(i int) string {
+	if i >= 0 && i < len(m.Params) {
+		return m.Params[i]
+	}
+	return ""
+}
+
+// trailing returns the last message parameter or empty string if there are no parameters
+func (m *ircMessage) trailing() string {
+	return m.param(len(m.Params) - 1)
+}
+
+// nick returns the nick of the message source
+func (m *ircMessage) nick() string {
+	if i := strings.IndexAny(m.Prefix, "!@"); i >= 0 {
+		return m.Prefix[:i]
+	}
+	return m.Prefix
+}
+
+// NewIRCMonitor creates new IRC channel monitor
+func NewIRCMonitor(c *IRCConfig) (*IRCMonitor, error) {
+	if c.Server == "" || c.Nick == "" {
+		return nil, fmt.Errorf("missing IRC server or nick")
+	}
+
+	if len(c.Channels) == 0 {
+		return nil, fmt.Errorf("missing IRC channels")
+	}
+
+	if c.SASLUser != "" && !c.TLS {
+		return nil, fmt.Errorf("IRC SASL authentication requires TLS")
+	}
+
+	// the monitor only receives messages from the channels it joined, so any channel matches
+	matcher, err := newMsgMatcher("IRC", nil, "", c.Sender, c.Msg, c.Resolve, newNameCache(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	var tlsConfig *tls.Config
+	if c.TLS {
+		tlsConfig = c.TLSConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName == "" {
+			host, _, err := net.SplitHostPort(c.Server)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IRC server %s: %s", c.Server, err)
+			}
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = host
+		}
+	}
+
+	reconnectDelay := c.ReconnectDelay
+	if reconnectDelay <= 0 {
+		reconnectDelay = DefaultIRCReconnectDelay
+	}
+
+	return &IRCMonitor{
+		server:         c.Server,
+		tlsConfig:      tlsConfig,
+		password:       c.Password,
+		nick:           c.Nick,
+		saslUser:       c.SASLUser,
+		saslPassword:   c.SASLPassword,
+		channels:       c.Channels,
+		sender:         c.Sender,
+		matcher:        matcher,
+		reconnectDelay: reconnectDelay,
+		Mutex:          &sync.Mutex{},
+	}, nil
+}
+
+// String returns the name of the monitor
+func (i *IRCMonitor) String() string {
+	return "IRC Monitor"
+}
+
+// Channels returns IRC channels which we monitor
+func (i *IRCMonitor) Channels() []string {
+	return i.channels
+}
+
+// Sender returns IRC nick whose messages we monitor
+func (i *IRCMonitor) Sender() string {
+	return i.sender
+}
+
+// dial connects to IRC server
+func (i *IRCMonitor) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: ircDialTimeout}
+	if i.tlsConfig != nil {
+		return tls.DialWithDialer(dialer, "tcp", i.server, i.tlsConfig)
+	}
+	return dialer.Dial("tcp", i.server)
+}
+
+// watchServer receives IRC messages until doneChan is closed
+// It reconnects when the connection drops after the monitor has registered with the server.
+func (i *IRCMonitor) watchServer(eventChan chan *msgEvent, errChan chan error, doneChan chan struct{}) {
+	for {
+		registered, err := i.session(eventChan, doneChan)
+		select {
+		case <-doneChan:
+			return
+		default:
+		}
+
+		if !registered || alertify.IsFatal(err) {
+			i.sendErr(errChan, doneChan, err)
+			return
+		}
+
+		log.Printf("IRC connection dropped: %v; reconnecting in %s", err, i.reconnectDelay)
+
+		select {
+		case <-time.After(i.reconnectDelay):
+		case <-doneChan:
+			return
+		}
+	}
+}
+
+// session connects to IRC server, joins the channels and receives their messages until the connection drops
+// It returns true if the monitor registered with the server before the connection dropped.
+func (i *IRCMonitor) session(eventChan chan *msgEvent, doneChan chan struct{}) (bool, error) {
+	conn, err := i.dial()
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to IRC server %s: %s", i.server, err)
+	}
+
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	go func() {
+		select {
+		case <-doneChan:
+			fmt.Fprintf(conn, "QUIT :Bye\r\n")
+		case <-closeChan:
+		}
+		conn.Close()
+	}()
+
+	send := func(format string, args ...interface{}) error {
+		if _, err := fmt.Fprintf(conn, format+"\r\n", args...); err != nil {
+			return fmt.Errorf("IRC write failed: %s", err)
+		}
+		return nil
+	}
+
+	if i.saslUser != "" {
+		if err := send("CAP REQ :sasl"); err != nil {
+			return false, err
+		}
+	}
+	if i.password != "" {
+		if err := send("PASS %s", i.password); err != nil {
+			return false, err
+		}
+	}
+
+	nick := i.nick
+	if err := send("NICK %s", nick); err != nil {
+		return false, err
+	}
+	if err := send("USER %s 0 * :%s", i.nick, i.nick); err != nil {
+		return false, err
+	}
+
+	registered := false
+	reader := bufio.NewReader(conn)
+
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(ircReadTimeout)); err != nil {
+			return registered, fmt.Errorf("IRC connection failed: %s", err)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return registered, fmt.Errorf("IRC connection failed: %s", err)
+		}
+
+		msg := parseIRCMessage(line)
+
+		switch msg.Command {
+		case "PING":
+			err = send("PONG :%s", msg.param(0))
+		case "CAP":
+			switch msg.param(1) {
+			case "ACK":
+				err = send("AUTHENTICATE PLAIN")
+			case "NAK":
+				return false, alertify.Fatal(fmt.Errorf("IRC server %s doesn't support SASL", i.server))
+			}
+		case "AUTHENTICATE":
+			if msg.param(0) == "+" {
+				err = i.authenticate(send)
+			}
+		case "903":
+			err = send("CAP END")
+		case "902", "904", "905", "906", "908":
+			return false, alertify.Fatal(fmt.Errorf("IRC SASL authentication failed: %s", msg.trailing()))
+		case "464", "465":
+			return false, alertify.Fatal(fmt.Errorf("IRC registration failed: %s", msg.trailing()))
+		case "432", "433", "436":
+			if registered {
+				continue
+			}
+			// try an alternative nick until the monitor registers with the server
+			nick += "_"
+			err = send("NICK %s", nick)
+		case "001":
+			registered = true
+			log.Printf("Connected to IRC server %s as %s", i.server, nick)
+			err = send("JOIN %s", strings.Join(i.channels, ","))
+		case "PRIVMSG":
+			if !containsFold(i.channels, msg.param(0)) {
+				continue
+			}
+			ev := &msgEvent{
+				Type:    "message",
+				Channel: msg.param(0),
+				User:    msg.nick(),
+				Text:    msg.param(1),
+			}
+			select {
+			case eventChan <- ev:
+			case <-doneChan:
+				return registered, nil
+			}
+		case "ERROR":
+			return registered, fmt.Errorf("IRC server closed connection: %s", msg.param(0))
+		}
+
+		if err != nil {
+			return registered, err
+		}
+	}
+}
+
+// authenticate sends SASL PLAIN credentials split into AUTHENTICATE messages
+func (i *IRCMonitor) authenticate(send func(string, ...interface{}) error) error {
+	creds := base64.StdEncoding.EncodeToString([]byte(i.saslUser + "\x00" + i.saslUser + "\x00" + i.saslPassword))
+
+	for len(creds) >= ircSASLChunk {
+		if err := send("AUTHENTICATE %s", creds[:ircSASLChunk]); err != nil {
+			return err
+		}
+		creds = creds[ircSASLChunk:]
+	}
+
+	// empty payload or the one which ends on chunk boundary is terminated by +
+	if creds == "" {
+		creds = "+"
+	}
+
+	return send("AUTHENTICATE %s", creds)
+}
+
+// sendErr sends err to errChan unless doneChan is closed
+func (i *IRCMonitor) sendErr(errChan chan error, doneChan chan struct{}, err error) {
+	select {
+	case errChan <- err:
+	case <-doneChan:
+	}
+}
+
+// MonitorAndAlert monitors IRC channels and notifies alertify Bot when the preconfigured message regexp is matched
+// Every call opens a new IRC connection, so the monitor can be restarted after it fails.
+func (i *IRCMonitor) MonitorAndAlert(msgChan chan<- *alertify.Msg) error {
+	doneChan := make(chan struct{})
+	i.Lock()
+	i.doneChan = doneChan
+	i.isRunning = true
+	i.Unlock()
+
+	// eventChan receives IRC message events
+	eventChan := make(chan *msgEvent, 16)
+	// errChan is error channel
+	errChan := make(chan error)
+	// watchDone stops server watcher when this run finishes
+	watchDone := make(chan struct{})
+	defer close(watchDone)
+
+	go i.watchServer(eventChan, errChan, watchDone)
+
+	for {
+		select {
+		case ev := <-eventChan:
+			match, err := i.matcher.match(ev)
+			if err != nil {
+				log.Printf("Could not match IRC message: %v", err)
+			}
+			if match == nil {
+				continue
+			}
+			// send message to alertify bot to play or silence song
+			i.matcher.act(msgChan, match)
+		case <-doneChan:
+			return nil
+		case err := <-errChan:
+			i.stopped()
+			return err
+		}
+	}
+}
+
+// stopped marks the monitor as not running
+func (i *IRCMonitor) stopped() {
+	i.Lock()
+	defer i.Unlock()
+
+	i.isRunning = false
+}
+
+// Stop stops IRC monitor
+func (i *IRCMonitor) Stop() {
+	i.Lock()
+	defer i.Unlock()
+
+	if i.isRunning {
+		close(i.doneChan)
+		i.isRunning = false
+	}
+}
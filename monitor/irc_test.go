@@ -0,0 +1,379 @@
+package monitor
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/milosgajdos/alertify"
+)
+
+// fakeIRCServer is in-process IRC server whose connections are driven by tests
+type fakeIRCServer struct {
+	// ln listens for IRC connections
+	ln net.Listener
+	// conns receives accepted connections
+	conns chan *fakeIRCConn
+	// clientTLS configures TLS connections to the server; nil clientTLS disables TLS
+	clientTLS *tls.Config
+}
+
+// fakeIRCConn is IRC connection accepted by fake IRC server
+type fakeIRCConn struct {
+	net.Conn
+	// reader reads lines sent by the client
+	reader *bufio.Reader
+}
+
+// newFakeIRCServer starts fake IRC server which optionally accepts TLS connections and returns it
+func newFakeIRCServer(t *testing.T, useTLS bool) *fakeIRCServer {
+	s := &fakeIRCServer{conns: make(chan *fakeIRCConn, 4)}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	if useTLS {
+		// borrow the certificate of httptest TLS server which is valid for 127.0.0.1
+		ts := httptest.NewTLSServer(http.NotFoundHandler())
+		ts.Close()
+		ln = tls.NewListener(ln, &tls.Config{Certificates: ts.TLS.Certificates})
+		s.clientTLS = ts.Client().Transport.(*http.Transport).TLSClientConfig
+	}
+
+	s.ln = ln
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			s.conns <- &fakeIRCConn{Conn: conn, reader: bufio.NewReader(conn)}
+		}
+	}()
+
+	return s
+}
+
+// accept returns the next client connection
+func (s *fakeIRCServer) accept(t *testing.T) *fakeIRCConn {
+	t.Helper()
+
+	select {
+	case conn := <-s.conns:
+		return conn
+	case <-time.After(5 * time.Second):
+		t.Fatal("client did not connect")
+		return nil
+	}
+}
+
+// expect reads the next line sent by the client and checks it's want
+func (c *fakeIRCConn) expect(t *testing.T, want string) {
+	t.Helper()
+
+	if err := c.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("failed to set read deadline: %v", err)
+	}
+
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read %q: %v", want, err)
+	}
+
+	if got := strings.TrimRight(line, "\r\n"); got != want {
+		t.Fatalf("client sent %q, want %q", got, want)
+	}
+}
+
+// send sends lines to the client
+func (c *fakeIRCConn) send(t *testing.T, lines ...string) {
+	t.Helper()
+
+	for _, line := range lines {
+		if _, err := c.Write([]byte(line + "\r\n")); err != nil {
+			t.Fatalf("failed to send %q: %v", line, err)
+		}
+	}
+}
+
+// drain discards lines sent by the client until the connection is closed
+func (c *fakeIRCConn) drain() {
+	for {
+		if _, err := c.reader.ReadString('\n'); err != nil {
+			return
+		}
+	}
+}
+
+func TestIRCMonitor(t *testing.T) {
+	s := newFakeIRCServer(t, false)
+
+	m, err := NewIRCMonitor(&IRCConfig{
+		Server:         s.ln.Addr().String(),
+		Password:       "secret",
+		Nick:           "alertify",
+		Channels:       []string{"#ops"},
+		Sender:         "nagios",
+		Msg:            "^ALERT",
+		Resolve:        "^RESOLVED",
+		ReconnectDelay: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to create monitor: %v", err)
+	}
+
+	msgChan := make(chan *alertify.Msg)
+	errChan := runMonitor(m, msgChan)
+
+	conn := s.accept(t)
+	conn.expect(t, "PASS secret")
+	conn.expect(t, "NICK alertify")
+	conn.expect(t, "USER alertify 0 * :alertify")
+	conn.send(t, ":irc.example.org 433 * alertify :Nickname is already in use")
+	conn.expect(t, "NICK alertify_")
+	conn.send(t, ":irc.example.org 001 alertify_ :Welcome to the Example IRC Network")
+	conn.expect(t, "JOIN #ops")
+	conn.send(t, "PING :irc.example.org")
+	conn.expect(t, "PONG :irc.example.org")
+
+	conn.send(t,
+		// other sender
+		":bob!bob@example.org PRIVMSG #ops :ALERT DiskFull",
+		// channel the monitor didn't join
+		":nagios!nagios@example.org PRIVMSG #random :ALERT DiskFull",
+		// private message
+		":nagios!nagios@example.org PRIVMSG alertify_ :ALERT DiskFull",
+		":nagios!nagios@example.org PRIVMSG #ops :ALERT DiskFull",
+	)
+
+	msg := receiveCommand(t, msgChan)
+	if msg.Cmd != ActionAlert {
+		t.Fatalf("command = %s, want %s", msg.Cmd, ActionAlert)
+	}
+	msg.Resp <- nil
+
+	// the monitor reconnects after the registered connection drops
+	conn.send(t, "ERROR :Closing Link: example.org (Ping timeout: 240 seconds)")
+	conn.Close()
+
+	conn = s.accept(t)
+	conn.expect(t, "PASS secret")
+	conn.expect(t, "NICK alertify")
+	conn.expect(t, "USER alertify 0 * :alertify")
+	conn.send(t, ":irc.example.org 001 alertify :Welcome to the Example IRC Network")
+	conn.expect(t, "JOIN #ops")
+	conn.send(t, "@time=2026-10-19T10:00:00.000Z :nagios!nagios@example.org PRIVMSG #OPS :RESOLVED DiskFull")
+
+	msg = receiveCommand(t, msgChan)
+	if msg.Cmd != ActionSilence {
+		t.Fatalf("command = %s, want %s", msg.Cmd, ActionSilence)
+	}
+	msg.Resp <- nil
+
+	expectNoCommand(t, msgChan, 100*time.Millisecond)
+
+	if err := stopMonitor(t, m, errChan); err != nil {
+		t.Errorf("MonitorAndAlert() error = %v", err)
+	}
+	conn.expect(t, "QUIT :Bye")
+}
+
+func TestIRCMonitorSASL(t *testing.T) {
+	s := newFakeIRCServer(t, true)
+
+	m, err := NewIRCMonitor(&IRCConfig{
+		Server:       s.ln.Addr().String(),
+		TLS:          true,
+		TLSConfig:    s.clientTLS,
+		Nick:         "alertify",
+		SASLUser:     "alertify",
+		SASLPassword: "secret",
+		Channels:     []string{"#ops", "#infra"},
+		Msg:          "^ALERT",
+	})
+	if err != nil {
+		t.Fatalf("failed to create monitor: %v", err)
+	}
+
+	msgChan := make(chan *alertify.Msg)
+	errChan := runMonitor(m, msgChan)
+
+	conn := s.accept(t)
+	conn.expect(t, "CAP REQ :sasl")
+	conn.expect(t, "NICK alertify")
+	conn.expect(t, "USER alertify 0 * :alertify")
+	conn.send(t, ":irc.example.org CAP * ACK :sasl")
+	conn.expect(t, "AUTHENTICATE PLAIN")
+	conn.send(t, "AUTHENTICATE +")
+	conn.expect(t, "AUTHENTICATE "+base64.StdEncoding.EncodeToString([]byte("alertify\x00alertify\x00secret")))
+	conn.send(t, ":irc.example.org 903 alertify :SASL authentication successful")
+	conn.expect(t, "CAP END")
+	conn.send(t, ":irc.example.org 001 alertify :Welcome to the Example IRC Network")
+	conn.expect(t, "JOIN #ops,#infra")
+	conn.send(t, ":grafana!grafana@example.org PRIVMSG #infra :ALERT HighLoad")
+
+	msg := receiveCommand(t, msgChan)
+	if msg.Cmd != ActionAlert {
+		t.Fatalf("command = %s, want %s", msg.Cmd, ActionAlert)
+	}
+	msg.Resp <- nil
+
+	if err := stopMonitor(t, m, errChan); err != nil {
+		t.Errorf("MonitorAndAlert() error = %v", err)
+	}
+}
+
+func TestIRCMonitorErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		sasl      bool
+		lines     []string
+		wantFatal bool
+	}{
+		{
+			name:      "password mismatch",
+			lines:     []string{":irc.example.org 464 alertify :Password incorrect"},
+			wantFatal: true,
+		},
+		{
+			name:      "password mismatch without params",
+			lines:     []string{"464"},
+			wantFatal: true,
+		},
+		{
+			name:      "banned",
+			lines:     []string{":irc.example.org 465 alertify :You are banned from this server"},
+			wantFatal: true,
+		},
+		{
+			name:  "closed before registration",
+			lines: []string{"ERROR :Closing Link: example.org (Throttled)"},
+		},
+		{
+			name:      "SASL not supported",
+			sasl:      true,
+			lines:     []string{":irc.example.org CAP * NAK :sasl"},
+			wantFatal: true,
+		},
+		{
+			name:      "SASL failed without params",
+			sasl:      true,
+			lines:     []string{":irc.example.org CAP * ACK :sasl", "AUTHENTICATE +", "904"},
+			wantFatal: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeIRCServer(t, true)
+
+			c := &IRCConfig{
+				Server:    s.ln.Addr().String(),
+				TLS:       true,
+				TLSConfig: s.clientTLS,
+				Nick:      "alertify",
+				Channels:  []string{"#ops"},
+				Msg:       "^ALERT",
+			}
+			if tt.sasl {
+				c.SASLUser, c.SASLPassword = "alertify", "secret"
+			}
+
+			m, err := NewIRCMonitor(c)
+			if err != nil {
+				t.Fatalf("failed to create monitor: %v", err)
+			}
+
+			errChan := runMonitor(m, make(chan *alertify.Msg))
+
+			conn := s.accept(t)
+			go conn.drain()
+			conn.send(t, tt.lines...)
+
+			select {
+			case err := <-errChan:
+				if err == nil || alertify.IsFatal(err) != tt.wantFatal {
+					t.Errorf("MonitorAndAlert() error = %v, want fatal %v", err, tt.wantFatal)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("monitor did not fail")
+			}
+		})
+	}
+}
+
+func TestParseIRCMessage(t *testing.T) {
+	tests := []struct {
+		line         string
+		want         *ircMessage
+		wantNick     string
+		wantTrailing string
+	}{
+		{
+			line:         ":nagios!nagios@example.org PRIVMSG #ops :ALERT DiskFull: / is 95% full\r\n",
+			want:         &ircMessage{Prefix: "nagios!nagios@example.org", Command: "PRIVMSG", Params: []string{"#ops", "ALERT DiskFull: / is 95% full"}},
+			wantNick:     "nagios",
+			wantTrailing: "ALERT DiskFull: / is 95% full",
+		},
+		{
+			line:         "@time=2026-10-19T10:00:00.000Z;msgid=abc :irc.example.org 001 alertify :Welcome",
+			want:         &ircMessage{Prefix: "irc.example.org", Command: "001", Params: []string{"alertify", "Welcome"}},
+			wantNick:     "irc.example.org",
+			wantTrailing: "Welcome",
+		},
+		{
+			line:         "ping :irc.example.org",
+			want:         &ircMessage{Command: "PING", Params: []string{"irc.example.org"}},
+			wantTrailing: "irc.example.org",
+		},
+		{
+			line:         ":irc.example.org CAP * ACK :",
+			want:         &ircMessage{Prefix: "irc.example.org", Command: "CAP", Params: []string{"*", "ACK", ""}},
+			wantNick:     "irc.example.org",
+			wantTrailing: "",
+		},
+		{
+			line: "464",
+			want: &ircMessage{Command: "464", Params: []string{}},
+		},
+		{
+			line:     ":irc.example.org",
+			want:     &ircMessage{Prefix: "irc.example.org"},
+			wantNick: "irc.example.org",
+		},
+		{
+			line: "",
+			want: &ircMessage{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			msg := parseIRCMessage(tt.line)
+			if !reflect.DeepEqual(msg, tt.want) {
+				t.Fatalf("parseIRCMessage() = %+v, want %+v", msg, tt.want)
+			}
+			if got := msg.nick(); got != tt.wantNick {
+				t.Errorf("nick() = %q, want %q", got, tt.wantNick)
+			}
+			if got := msg.trailing(); got != tt.wantTrailing {
+				t.Errorf("trailing() = %q, want %q", got, tt.wantTrailing)
+			}
+			if got := msg.param(-1); got != "" {
+				t.Errorf("param(-1) = %q, want empty", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/milosgajdos/alertify"
+)
+
+const (
+	// DefaultMatrixSyncTimeout is the default Matrix sync long polling timeout
+	DefaultMatrixSyncTimeout = 30 * time.Second
+	// matrixRequestSlack is how much longer than sync timeout Matrix API requests may take
+	matrixRequestSlack = 10 * time.Second
+)
+
+// MatrixConfig configures Matrix monitor
+type MatrixConfig struct {
+	// Homeserver is Matrix homeserver URL, e.g. https://matrix.org
+	Homeserver string
+	// AccessToken is Matrix access token of the monitoring user
+	AccessToken string
+	// Room is Matrix room ID or alias, e.g. !abc:matrix.org or #infra:matrix.org
+	Room string
+	// Sender is Matrix user ID which posts the messages, e.g. @nagios:matrix.org.
+	// Empty Sender matches messages posted by anyone.
+	Sender string
+	// Msg is the message we are matching for
+	Msg string
+	// Resolve is the resolve message we are matching for. Resolve messages silence the alert.
+	// Its capture group can extract alert fingerprint; see FingerprintGroup.
+	Resolve string
+	// SyncTimeout is sync long polling timeout. It defaults to DefaultMatrixSyncTimeout.
+	SyncTimeout time.Duration
+}
+
+// MatrixMonitor monitors Matrix room messages received via client-server sync API
+type MatrixMonitor struct {
+	// homeserver is Matrix homeserver URL
+	homeserver string
+	// token is Matrix access token
+	token string
+	// room is Matrix room ID or alias
+	room string
+	// sender is Matrix user ID
+	sender string
+	// matcher matches Matrix messages.
+	// It's kept across monitor restarts, so it keeps track of the firing alerts.
+	matcher *msgMatcher
+	// timeout is sync long polling timeout
+	timeout time.Duration
+	// client sends API requests
+	client *http.Client
+	// since is the sync token of the last received batch
+	since string
+	// doneChan stops the monitor
+	doneChan chan struct{}
+	// isRunning checks if monitor is running
+	isRunning bool
+	// mutex
+	*sync.Mutex
+}
+
+// matrixSync is Matrix sync response
+type matrixSync struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []*matrixEvent `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+	} `json:"rooms"`
+}
+
+// matrixEvent is Matrix room event
+type matrixEvent struct {
+	Type    string `json:"type"`
+	EventID string `json:"event_id"`
+	Sender  string `json:"sender"`
+	Content struct {
+		MsgType string `json:"msgtype"`
+		Body    string `json:"body"`
+	} `json:"content"`
+}
+
+// NewMatrixMonitor creates new Matrix room monitor
+func NewMatrixMonitor(c *MatrixConfig) (*MatrixMonitor, error) {
+	if c.Homeserver == "" || c.AccessToken == "" {
+		return nil, fmt.Errorf("missing Matrix homeserver or access token")
+	}
+
+	if c.Room == "" {
+		return nil, fmt.Errorf("missing Matrix room")
+	}
+
+	if _, err := url.Parse(c.Homeserver); err != nil {
+		return nil, fmt.Errorf("invalid Matrix homeserver %s: %s", c.Homeserver, err)
+	}
+
+	// sync only returns the monitored room messages, so the matcher matches messages in any room
+	matcher, err := newMsgMatcher("Matrix", nil, "", c.Sender, c.Msg, c.Resolve, newNameCache(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := c.SyncTimeout
+	if timeout <= 0 {
+		timeout = DefaultMatrixSyncTimeout
+	}
+
+	return &MatrixMonitor{
+		homeserver: strings.TrimSuffix(c.Homeserver, "/"),
+		token:      c.AccessToken,
+		room:       c.Room,
+		sender:     c.Sender,
+		matcher:    matcher,
+		timeout:    timeout,
+		client:     &http.Client{Timeout: timeout + matrixRequestSlack},
+		Mutex:      &sync.Mutex{},
+	}, nil
+}
+
+// String returns the name of the monitor
+func (m *MatrixMonitor) String() string {
+	return "Matrix Monitor"
+}
+
+// Room returns Matrix room which we monitor
+func (m *MatrixMonitor) Room() string {
+	return m.room
+}
+
+// Sender returns Matrix user ID whose messages we monitor
+func (m *MatrixMonitor) Sender() string {
+	return m.sender
+}
+
+// get sends GET request to Matrix client-server API path and decodes the response into v
+func (m *MatrixMonitor) get(ctx context.Context, path string, query url.Values, v interface{}) error {
+	u := m.homeserver + "/_matrix/client/v3/" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+m.token)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Matrix API request failed: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var merr struct {
+			ErrCode string `json:"errcode"`
+			Error   string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&merr); err != nil {
+			merr.Error = fmt.Sprintf("invalid error response: %s", err)
+		}
+
+		err := fmt.Errorf("Matrix API request failed: %s: %s %s", resp.Status, merr.ErrCode, merr.Error)
+		if resp.StatusCode == http.StatusUnauthorized || merr.ErrCode == "M_UNKNOWN_TOKEN" {
+			// restarting the monitor won't fix bad credentials
+			return alertify.Fatal(err)
+		}
+		return err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid Matrix API response: %s", err)
+	}
+
+	return nil
+}
+
+// resolveRoom returns ID of the monitored room resolving room alias if necessary
+func (m *MatrixMonitor) resolveRoom(ctx context.Context) (string, error) {
+	if !strings.HasPrefix(m.room, "#") {
+		return m.room, nil
+	}
+
+	var resp struct {
+		RoomID string `json:"room_id"`
+	}
+
+	if err := m.get(ctx, "directory/room/"+url.PathEscape(m.room), nil, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.RoomID, nil
+}
+
+// sync returns Matrix room events received since the since sync token
+func (m *MatrixMonitor) sync(ctx context.Context, roomID, since string, timeout time.Duration) (*matrixSync, error) {
+	filter, err := json.Marshal(map[string]interface{}{
+		"presence":     map[string]interface{}{"types": []string{}},
+		"account_data": map[string]interface{}{"types": []string{}},
+		"room": map[string]interface{}{
+			"rooms":    []string{roomID},
+			"timeline": map[string]interface{}{"types": []string{"m.room.message"}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{
+		"filter":  {string(filter)},
+		"timeout": {fmt.Sprintf("%d", timeout/time.Millisecond)},
+	}
+	if since != "" {
+		query.Set("since", since)
+	}
+
+	resp := new(matrixSync)
+	if err := m.get(ctx, "sync", query, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// newMatrixEvent converts Matrix room event to message event
+func newMatrixEvent(roomID string, ev *matrixEvent) *msgEvent {
+	return &msgEvent{
+		Type:    "message",
+		Channel: roomID,
+		User:    ev.Sender,
+		Text:    ev.Content.Body,
+		TS:      ev.EventID,
+	}
+}
+
+// MonitorAndAlert monitors Matrix room and notifies alertify Bot when a message matches the alert
+// or resolve message RegExp. Messages posted before the monitor started for the first time are ignored.
+func (m *MatrixMonitor) MonitorAndAlert(msgChan chan<- *alertify.Msg) error {
+	doneChan := make(chan struct{})
+	m.Lock()
+	m.doneChan = doneChan
+	m.isRunning = true
+	m.Unlock()
+
+	// ctx cancels pending sync requests when the monitor is stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-doneChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := m.watchRoom(ctx, msgChan)
+
+	select {
+	case <-doneChan:
+		return nil
+	default:
+	}
+
+	m.Lock()
+	m.isRunning = false
+	m.Unlock()
+
+	return err
+}
+
+// watchRoom syncs the monitored room and acts upon the matching messages until ctx is canceled
+func (m *MatrixMonitor) watchRoom(ctx context.Context, msgChan chan<- *alertify.Msg) error {
+	roomID, err := m.resolveRoom(ctx)
+	if err != nil {
+		return err
+	}
+
+	// initial sync only fetches the sync token so the old messages are not replayed
+	if m.since == "" {
+		resp, err := m.sync(ctx, roomID, "", 0)
+		if err != nil {
+			return err
+		}
+		m.since = resp.NextBatch
+		log.Printf("Connected to Matrix homeserver %s", m.homeserver)
+	}
+
+	for {
+		resp, err := m.sync(ctx, roomID, m.since, m.timeout)
+		if err != nil {
+			return err
+		}
+
+		for _, ev := range resp.Rooms.Join[roomID].Timeline.Events {
+			if ev.Type != "m.room.message" {
+				continue
+			}
+
+			match, err := m.matcher.match(newMatrixEvent(roomID, ev))
+			if err != nil {
+				log.Printf("Could not match Matrix message: %v", err)
+				if alertify.IsFatal(err) {
+					return err
+				}
+			}
+			if match == nil {
+				continue
+			}
+			// send message to alertify bot to play or silence song
+			m.matcher.act(msgChan, match)
+		}
+
+		m.since = resp.NextBatch
+	}
+}
+
+// Stop stops Matrix monitor
+func (m *MatrixMonitor) Stop() {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.isRunning {
+		close(m.doneChan)
+		m.isRunning = false
+	}
+}
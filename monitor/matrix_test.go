@@ -0,0 +1,246 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/milosgajdos/alertify"
+)
+
+const testMatrixToken = "syt_YWxlcnRpZnk_token"
+
+// fakeHomeserver is fake Matrix homeserver which serves room timeline in sync batches
+type fakeHomeserver struct {
+	// srv serves Matrix client-server API
+	srv *httptest.Server
+	// batches are room events indexed by the since token of the sync which returns them
+	batches map[string][]string
+	// status is HTTP status of all responses unless it's zero
+	status int
+	// body is response body sent with status
+	body string
+}
+
+// newFakeHomeserver starts fake Matrix homeserver and returns it
+func newFakeHomeserver(t *testing.T, batches map[string][]string) *fakeHomeserver {
+	h := &fakeHomeserver{batches: batches}
+
+	h.srv = httptest.NewServer(http.HandlerFunc(h.serve))
+	t.Cleanup(h.srv.Close)
+
+	return h
+}
+
+// serve serves room directory and sync API
+// Sync with since token sN returns the batch of sN and the next batch token sN+1.
+func (h *fakeHomeserver) serve(w http.ResponseWriter, r *http.Request) {
+	if h.status != 0 {
+		w.WriteHeader(h.status)
+		fmt.Fprint(w, h.body)
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+testMatrixToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"errcode":"M_UNKNOWN_TOKEN","error":"Invalid access token passed."}`)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/_matrix/client/v3/directory/room/#infra:example.org":
+		fmt.Fprint(w, `{"room_id":"!abc:example.org","servers":["example.org"]}`)
+	case "/_matrix/client/v3/sync":
+		if !strings.Contains(r.URL.Query().Get("filter"), `"!abc:example.org"`) {
+			http.Error(w, `{"errcode":"M_INVALID_PARAM","error":"unfiltered sync"}`, http.StatusBadRequest)
+			return
+		}
+
+		since := r.URL.Query().Get("since")
+		events, ok := h.batches[since]
+		if !ok {
+			// long polling sync times out if there are no new events
+			select {
+			case <-r.Context().Done():
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+
+		// the initial sync has no since token
+		n, _ := strconv.Atoi(strings.TrimPrefix(since, "s"))
+		fmt.Fprintf(w, `{"next_batch":"s%d","rooms":{"join":{"!abc:example.org":{"timeline":{"events":[%s]}}}}}`,
+			n+1, strings.Join(events, ","))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errcode":"M_NOT_FOUND","error":"Room alias not found"}`)
+	}
+}
+
+// roomMessage returns Matrix room message event posted by sender
+func roomMessage(id, sender, body string) string {
+	ev, _ := json.Marshal(map[string]interface{}{
+		"type":     "m.room.message",
+		"event_id": id,
+		"sender":   sender,
+		"content":  map[string]string{"msgtype": "m.text", "body": body},
+	})
+
+	return string(ev)
+}
+
+func TestMatrixMonitor(t *testing.T) {
+	h := newFakeHomeserver(t, map[string][]string{
+		// messages posted before the monitor started are not replayed
+		"": {roomMessage("$0", "@nagios:example.org", "ALERT DiskFull [db0]")},
+		"s1": {
+			roomMessage("$1", "@bob:example.org", "ALERT DiskFull [db1]"),
+			`{"type":"m.room.member","event_id":"$2","sender":"@nagios:example.org","content":{"membership":"join"}}`,
+			roomMessage("$3", "@nagios:example.org", "ALERT DiskFull [db1]"),
+		},
+		"s2": {roomMessage("$4", "@nagios:example.org", "RESOLVED DiskFull [db1]")},
+	})
+
+	m, err := NewMatrixMonitor(&MatrixConfig{
+		Homeserver:  h.srv.URL + "/",
+		AccessToken: testMatrixToken,
+		Room:        "#infra:example.org",
+		Sender:      "@nagios:example.org",
+		Msg:         `^ALERT \w+ \[(\w+)\]`,
+		Resolve:     `^RESOLVED \w+ \[(\w+)\]`,
+		SyncTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("failed to create monitor: %v", err)
+	}
+
+	msgChan := make(chan *alertify.Msg)
+	errChan := runMonitor(m, msgChan)
+
+	msg := receiveCommand(t, msgChan)
+	if msg.Cmd != ActionAlert {
+		t.Fatalf("command = %s, want %s", msg.Cmd, ActionAlert)
+	}
+	if fp := msg.Data.(*alertify.Alert).Labels[FingerprintGroup]; fp != "db1" {
+		t.Errorf("fingerprint = %q, want db1", fp)
+	}
+	msg.Resp <- nil
+
+	msg = receiveCommand(t, msgChan)
+	if msg.Cmd != ActionSilence {
+		t.Fatalf("command = %s, want %s", msg.Cmd, ActionSilence)
+	}
+	msg.Resp <- nil
+
+	expectNoCommand(t, msgChan, 200*time.Millisecond)
+
+	if err := stopMonitor(t, m, errChan); err != nil {
+		t.Errorf("MonitorAndAlert() error = %v", err)
+	}
+}
+
+func TestMatrixMonitorErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		room      string
+		token     string
+		status    int
+		body      string
+		wantFatal bool
+	}{
+		{
+			name:      "invalid token",
+			room:      "!abc:example.org",
+			token:     "invalid",
+			wantFatal: true,
+		},
+		{
+			name: "unknown room alias",
+			room: "#unknown:example.org",
+		},
+		{
+			name:   "proxy error",
+			room:   "!abc:example.org",
+			status: http.StatusBadGateway,
+			body:   "<html><body>502 Bad Gateway</body></html>",
+		},
+		{
+			name:      "unauthorized without error body",
+			room:      "!abc:example.org",
+			status:    http.StatusUnauthorized,
+			wantFatal: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newFakeHomeserver(t, nil)
+			h.status = tt.status
+			h.body = tt.body
+
+			token := tt.token
+			if token == "" {
+				token = testMatrixToken
+			}
+
+			m, err := NewMatrixMonitor(&MatrixConfig{
+				Homeserver:  h.srv.URL,
+				AccessToken: token,
+				Room:        tt.room,
+				Msg:         "^ALERT",
+			})
+			if err != nil {
+				t.Fatalf("failed to create monitor: %v", err)
+			}
+
+			select {
+			case err := <-runMonitor(m, make(chan *alertify.Msg)):
+				if err == nil || alertify.IsFatal(err) != tt.wantFatal {
+					t.Errorf("MonitorAndAlert() error = %v, want fatal %v", err, tt.wantFatal)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("monitor did not fail")
+			}
+		})
+	}
+}
+
+func TestNewMatrixMonitor(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *MatrixConfig
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			config: &MatrixConfig{Homeserver: "https://example.org", AccessToken: testMatrixToken, Room: "#infra:example.org", Msg: "^ALERT"},
+		},
+		{
+			name:    "missing access token",
+			config:  &MatrixConfig{Homeserver: "https://example.org", Room: "#infra:example.org"},
+			wantErr: true,
+		},
+		{
+			name:    "missing room",
+			config:  &MatrixConfig{Homeserver: "https://example.org", AccessToken: testMatrixToken},
+			wantErr: true,
+		},
+		{
+			name:    "invalid message",
+			config:  &MatrixConfig{Homeserver: "https://example.org", AccessToken: testMatrixToken, Room: "#infra:example.org", Msg: "^ALERT ("},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewMatrixMonitor(tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("NewMatrixMonitor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,626 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/milosgajdos/alertify"
+)
+
+// msgEvent is chat message event
+// Slack, Discord, Matrix and IRC messages are all converted to msgEvent, so they share the matcher.
+type msgEvent struct {
+	// Type is the event type; only "message" events are matched
+	Type string
+	// Subtype is the message subtype, e.g. bot_message
+	Subtype string
+	// Channel is the ID of the channel or room the message was posted in
+	Channel string
+	// User is the ID of the user who posted the message
+	User string
+	// BotID is the ID of the bot which posted the message
+	BotID string
+	// Username is the name of the bot or webhook which posted the message
+	Username string
+	// Text is the message text
+	Text string
+	// TS identifies the message
+	TS string
+	// ThreadTS identifies the thread the message was posted in
+	ThreadTS string
+}
+
+// msgNames looks up names of chat users, bots and channels by their IDs
+type msgNames interface {
+	// userName returns the name of user with the given ID
+	userName(id string) (string, error)
+	// botName returns the name of bot with the given ID
+	botName(id string) (string, error)
+	// channelName returns the name of channel with the given ID
+	channelName(id string) (string, error)
+	// identity returns IDs of user and bot the names are looked up with
+	identity() (string, string, error)
+}
+
+// nameCache caches chat user, bot and channel names indexed by their IDs
+type nameCache struct {
+	// names looks up the names which are not cached
+	names msgNames
+	// cache contains cached names
+	cache map[string]string
+	// self contains IDs of user and bot the names are looked up with
+	self []string
+	// mutex
+	*sync.Mutex
+}
+
+// newNameCache creates new name cache which looks up names with names and returns it
+// Cache with nil names returns empty names.
+func newNameCache(names msgNames) *nameCache {
+	return &nameCache{
+		names: names,
+		cache: make(map[string]string),
+		Mutex: &sync.Mutex{},
+	}
+}
+
+// get returns the name cached under id or looks it up and caches it
+func (c *nameCache) get(id string, lookup func(string) (string, error)) (string, error) {
+	if id == "" || c.names == nil {
+		return "", nil
+	}
+
+	c.Lock()
+	name, ok := c.cache[id]
+	c.Unlock()
+
+	if ok {
+		return name, nil
+	}
+
+	name, err := lookup(id)
+	if err != nil {
+		return "", err
+	}
+
+	c.Lock()
+	c.cache[id] = name
+	c.Unlock()
+
+	return name, nil
+}
+
+// user returns the name of user with the given ID
+func (c *nameCache) user(id string) (string, error) {
+	return c.get(id, func(id string) (string, error) { return c.names.userName(id) })
+}
+
+// bot returns the name of bot with the given ID
+func (c *nameCache) bot(id string) (string, error) {
+	return c.get(id, func(id string) (string, error) { return c.names.botName(id) })
+}
+
+// channel returns the name of channel with the given ID
+func (c *nameCache) channel(id string) (string, error) {
+	return c.get(id, func(id string) (string, error) { return c.names.channelName(id) })
+}
+
+// own returns true if the message was posted by the user or bot the names are looked up with
+// These are the messages the monitor posts itself, e.g. its replies. Cache with nil names owns no messages.
+func (c *nameCache) own(ev *msgEvent) (bool, error) {
+	if c.names == nil {
+		return false, nil
+	}
+
+	c.Lock()
+	self := c.self
+	c.Unlock()
+
+	if self == nil {
+		user, bot, err := c.names.identity()
+		if err != nil {
+			return false, err
+		}
+		self = []string{user, bot}
+
+		c.Lock()
+		c.self = self
+		c.Unlock()
+	}
+
+	for _, id := range self {
+		if id != "" && (ev.User == id || ev.BotID == id) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Rule configures chat messages the monitor acts upon and the action it takes
+type Rule struct {
+	// Name is the rule name
+	Name string
+	// Channels are names or IDs of chat channels or rooms.
+	// Empty Channels match messages in all channels the monitor receives messages from.
+	Channels []string
+	// Authors are names or IDs of chat users or bots which post the messages.
+	// Empty Authors match messages posted by anyone.
+	Authors []string
+	// Include is RegExp the message must match
+	Include string
+	// Exclude is RegExp the message must not match
+	Exclude string
+	// Action is either ActionAlert or ActionSilence. It defaults to ActionAlert.
+	Action string
+	// Song overrides the alert song selected by the alert route
+	Song *alertify.Song
+	// Severity is the alert severity sent in the alert severity label
+	Severity string
+}
+
+const (
+	// FingerprintGroup is the name of Include RegExp capture group which extracts alert fingerprint.
+	// If Include RegExp has no named capture groups, its first capture group extracts the fingerprint.
+	// Silence rules with fingerprint only silence the alert once all the alerts played by the monitor
+	// with fingerprint have been resolved. Resolved alerts which have not been played are ignored.
+	FingerprintGroup = "fingerprint"
+	// SongGroup is the name of Include RegExp capture group which extracts the alert song URI.
+	// All other named capture groups are sent to bot as alert labels.
+	SongGroup = "song"
+)
+
+const (
+	// ActionAlert plays the alert
+	ActionAlert = "alert"
+	// ActionSilence silences the alert
+	ActionSilence = "silence"
+)
+
+// commandTimeout is the maximum time monitors wait for bot to accept and respond to a command
+const commandTimeout = 30 * time.Second
+
+// msgRule is compiled message rule
+type msgRule struct {
+	// name is the rule name
+	name string
+	// channels are names or IDs of chat channels
+	channels []string
+	// authors are names or IDs of chat users or bots
+	authors []string
+	// include is RegExp the message must match
+	include *regexp.Regexp
+	// exclude is RegExp the message must not match
+	exclude *regexp.Regexp
+	// action is the rule action
+	action string
+	// song is the alert song
+	song *alertify.Song
+	// severity is the alert severity
+	severity string
+}
+
+// newMsgRule compiles message rule and returns it
+func newMsgRule(r *Rule) (*msgRule, error) {
+	action := r.Action
+	if action == "" {
+		action = ActionAlert
+	}
+
+	if action != ActionAlert && action != ActionSilence {
+		return nil, fmt.Errorf("invalid rule %q action: %s", r.Name, action)
+	}
+
+	include, err := regexp.Compile(r.Include)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule %q include regexp: %s", r.Name, err)
+	}
+
+	var exclude *regexp.Regexp
+	if r.Exclude != "" {
+		if exclude, err = regexp.Compile(r.Exclude); err != nil {
+			return nil, fmt.Errorf("invalid rule %q exclude regexp: %s", r.Name, err)
+		}
+	}
+
+	rule := &msgRule{
+		name:     r.Name,
+		include:  include,
+		exclude:  exclude,
+		action:   action,
+		song:     r.Song,
+		severity: r.Severity,
+	}
+
+	for _, channel := range r.Channels {
+		if channel = strings.TrimPrefix(channel, "#"); channel != "" {
+			rule.channels = append(rule.channels, channel)
+		}
+	}
+
+	for _, author := range r.Authors {
+		if author != "" {
+			rule.authors = append(rule.authors, author)
+		}
+	}
+
+	return rule, nil
+}
+
+// fingerprint extracts alert fingerprint from message text
+// It returns empty string if the include RegExp has no fingerprint capture group.
+func (r *msgRule) fingerprint(text string) string {
+	group := 1
+	if named := namedGroups(r.include); len(named) > 0 {
+		if group = named[FingerprintGroup]; group == 0 {
+			return ""
+		}
+	}
+
+	if group > r.include.NumSubexp() {
+		return ""
+	}
+
+	if match := r.include.FindStringSubmatch(text); match != nil {
+		return match[group]
+	}
+
+	return ""
+}
+
+// captures returns values of include RegExp named capture groups matched in message text
+// Empty values are omitted.
+func (r *msgRule) captures(text string) map[string]string {
+	captures := make(map[string]string)
+
+	match := r.include.FindStringSubmatch(text)
+	if match == nil {
+		return captures
+	}
+
+	for name, i := range namedGroups(r.include) {
+		if match[i] != "" {
+			captures[name] = match[i]
+		}
+	}
+
+	return captures
+}
+
+// namedGroups returns indices of re named capture groups indexed by their names
+func namedGroups(re *regexp.Regexp) map[string]int {
+	groups := make(map[string]int)
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			groups[name] = i
+		}
+	}
+
+	return groups
+}
+
+// msgMatcher matches chat messages against message rules
+type msgMatcher struct {
+	// source is the name of the messaging service the messages come from
+	source string
+	// rules are message rules matched in order
+	rules []*msgRule
+	// names resolves channel, user and bot IDs to names
+	names *nameCache
+	// firing contains fingerprints of the alerts played by the monitor
+	firing map[string]bool
+	// mutex
+	*sync.Mutex
+}
+
+// newMsgMatcher creates new matcher of messages coming from source and returns it
+// If there are no rules, the matcher matches messages posted by user in channel which match msg
+// and, if resolve is not empty, it silences the alert when their messages match resolve.
+func newMsgMatcher(source string, rules []*Rule, channel, user, msg, resolve string, names *nameCache) (*msgMatcher, error) {
+	if len(rules) == 0 {
+		// resolve messages might match alert message RegExp too
+		if resolve != "" {
+			rules = append(rules, &Rule{
+				Channels: []string{channel},
+				Authors:  []string{user},
+				Include:  resolve,
+				Action:   ActionSilence,
+			})
+		}
+		rules = append(rules, &Rule{
+			Channels: []string{channel},
+			Authors:  []string{user},
+			Include:  msg,
+		})
+	}
+
+	m := &msgMatcher{
+		source: source,
+		names:  names,
+		firing: make(map[string]bool),
+		Mutex:  &sync.Mutex{},
+	}
+	for _, r := range rules {
+		rule, err := newMsgRule(r)
+		if err != nil {
+			return nil, err
+		}
+		m.rules = append(m.rules, rule)
+	}
+
+	return m, nil
+}
+
+// match matches message event ev against the rules in order
+// It returns nil if no rule matches the message. Messages posted by the monitor itself are never matched.
+func (m *msgMatcher) match(ev *msgEvent) (*msgMatch, error) {
+	if ev.Type != "message" || ev.Text == "" {
+		return nil, nil
+	}
+
+	for _, r := range m.rules {
+		if !r.include.MatchString(ev.Text) || (r.exclude != nil && r.exclude.MatchString(ev.Text)) {
+			continue
+		}
+
+		ok, err := m.matchChannel(r, ev)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		ok, err = m.matchAuthor(r, ev)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		// replies posted by the monitor might match the rules, too
+		own, err := m.names.own(ev)
+		if err != nil || own {
+			return nil, err
+		}
+
+		return &msgMatch{
+			rule:        r,
+			event:       ev,
+			fingerprint: r.fingerprint(ev.Text),
+			captures:    r.captures(ev.Text),
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// matchChannel returns true if the message was posted in one of the rule channels
+func (m *msgMatcher) matchChannel(r *msgRule, ev *msgEvent) (bool, error) {
+	if len(r.channels) == 0 {
+		return true, nil
+	}
+
+	for _, channel := range r.channels {
+		if ev.Channel == channel {
+			return true, nil
+		}
+	}
+
+	name, err := m.names.channel(ev.Channel)
+	if err != nil || name == "" {
+		return false, err
+	}
+
+	return containsFold(r.channels, name), nil
+}
+
+// matchAuthor returns true if the message was posted by one of the rule users or bots
+func (m *msgMatcher) matchAuthor(r *msgRule, ev *msgEvent) (bool, error) {
+	if len(r.authors) == 0 {
+		return true, nil
+	}
+
+	for _, author := range []string{ev.User, ev.BotID, ev.Username} {
+		if author != "" && containsFold(r.authors, author) {
+			return true, nil
+		}
+	}
+
+	name, err := m.names.user(ev.User)
+	if err != nil {
+		return false, err
+	}
+	if name != "" && containsFold(r.authors, name) {
+		return true, nil
+	}
+
+	name, err = m.names.bot(ev.BotID)
+	if err != nil {
+		return false, err
+	}
+
+	return name != "" && containsFold(r.authors, name), nil
+}
+
+// containsFold returns true if values contain s under Unicode case-folding
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// msgMatch is message event matched by message rule
+type msgMatch struct {
+	// rule is the matching rule
+	rule *msgRule
+	// event is the matched message event
+	event *msgEvent
+	// fingerprint is the alert fingerprint extracted from the message
+	fingerprint string
+	// captures are named capture groups extracted from the message
+	captures map[string]string
+}
+
+// command returns bot command and its data the match sends to bot
+// Alert labels extracted from the message override the rule severity
+// and the extracted song overrides the rule song.
+func (m *msgMatch) command() (string, interface{}) {
+	if m.rule.action == ActionSilence {
+		return ActionSilence, nil
+	}
+
+	labels := make(map[string]string)
+	if m.rule.name != "" {
+		labels["rule"] = m.rule.name
+	}
+	if m.rule.severity != "" {
+		labels["severity"] = m.rule.severity
+	}
+	if m.fingerprint != "" {
+		labels[FingerprintGroup] = m.fingerprint
+	}
+
+	song := m.rule.song
+	for name, value := range m.captures {
+		if name != SongGroup {
+			labels[name] = value
+			continue
+		}
+		s := alertify.Song{}
+		if song != nil {
+			s = *song
+		}
+		s.URI = linkURL(value)
+		song = &s
+	}
+
+	return ActionAlert, &alertify.Alert{Labels: labels, Song: song}
+}
+
+// act sends the match command to bot and returns bot response
+// Resolved alerts are only silenced if they have been firing and there are no other alerts firing.
+// It returns false if no command has been sent to bot.
+func (m *msgMatcher) act(msgChan chan<- *alertify.Msg, match *msgMatch) (interface{}, bool) {
+	cmd, data := match.command()
+	log.Printf("%s %s message match detected!", m.source, cmd)
+
+	if cmd == ActionSilence && match.fingerprint != "" {
+		firing, ok := m.resolve(match.fingerprint)
+		if !ok {
+			log.Printf("Alert %s resolved, but it has not been firing", match.fingerprint)
+			return nil, false
+		}
+		if firing > 0 {
+			log.Printf("Alert %s resolved, %d alerts still firing", match.fingerprint, firing)
+			return nil, false
+		}
+	}
+
+	resp := sendCommand(msgChan, cmd, data)
+	if err := alertify.RespError(resp); err != nil {
+		switch cmd {
+		case ActionSilence:
+			log.Printf("Could not silence alert: %v", err)
+		default:
+			log.Printf("Could not play song: %v", err)
+		}
+		return resp, true
+	}
+
+	switch cmd {
+	case ActionSilence:
+		m.clear()
+	case ActionAlert:
+		m.fire(match.fingerprint)
+	}
+
+	return resp, true
+}
+
+// fire records alert with fingerprint as firing
+func (m *msgMatcher) fire(fingerprint string) {
+	if fingerprint == "" {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.firing[fingerprint] = true
+}
+
+// resolve records alert with fingerprint as resolved and returns the number of alerts still firing
+// It returns false if the alert has not been firing.
+func (m *msgMatcher) resolve(fingerprint string) (int, bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.firing[fingerprint] {
+		return len(m.firing), false
+	}
+	delete(m.firing, fingerprint)
+
+	return len(m.firing), true
+}
+
+// clear records all alerts as resolved
+func (m *msgMatcher) clear() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.firing = make(map[string]bool)
+}
+
+// linkURL returns the URL of chat formatted link, e.g. Slack <https://example.com|example>
+// Text which is not a formatted link is returned unchanged.
+func linkURL(text string) string {
+	if !strings.HasPrefix(text, "<") || !strings.HasSuffix(text, ">") {
+		return text
+	}
+
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "<"), ">")
+	if i := strings.Index(text, "|"); i >= 0 {
+		text = text[:i]
+	}
+
+	return text
+}
+
+// sendCommand sends command to bot and returns its response
+// It returns error if the bot does not accept the command or respond to it within commandTimeout,
+// e.g. when the bot has stopped listening to the monitor.
+func sendCommand(msgChan chan<- *alertify.Msg, cmd string, data interface{}) interface{} {
+	// response channel is buffered so the bot doesn't block on timed out commands
+	respChan := make(chan interface{}, 1)
+	msg := &alertify.Msg{
+		Cmd:  cmd,
+		Data: data,
+		Resp: respChan,
+	}
+
+	timer := time.NewTimer(commandTimeout)
+	defer timer.Stop()
+
+	select {
+	case msgChan <- msg:
+	case <-timer.C:
+		return fmt.Errorf("bot did not accept %s command within %s", cmd, commandTimeout)
+	}
+
+	select {
+	case resp := <-respChan:
+		return resp
+	case <-timer.C:
+		return fmt.Errorf("bot did not respond to %s command within %s", cmd, commandTimeout)
+	}
+}
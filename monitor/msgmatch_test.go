@@ -0,0 +1,365 @@
+package monitor
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/milosgajdos/alertify"
+)
+
+// receiveCommand returns the next command monitor sends to bot
+func receiveCommand(t *testing.T, msgChan chan *alertify.Msg) *alertify.Msg {
+	t.Helper()
+
+	select {
+	case msg := <-msgChan:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("monitor did not send any command")
+		return nil
+	}
+}
+
+// expectNoCommand fails the test if monitor sends a command to bot within d
+func expectNoCommand(t *testing.T, msgChan chan *alertify.Msg, d time.Duration) {
+	t.Helper()
+
+	select {
+	case msg := <-msgChan:
+		t.Fatalf("unexpected %s command", msg.Cmd)
+	case <-time.After(d):
+	}
+}
+
+// runMonitor runs monitor and returns channel which receives its MonitorAndAlert result
+func runMonitor(m alertify.Monitor, msgChan chan *alertify.Msg) chan error {
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- m.MonitorAndAlert(msgChan)
+	}()
+
+	return errChan
+}
+
+// stopMonitor stops monitor and returns its MonitorAndAlert result
+func stopMonitor(t *testing.T, m alertify.Monitor, errChan chan error) error {
+	t.Helper()
+
+	m.Stop()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("monitor did not stop")
+		return nil
+	}
+}
+
+// staticNames looks up names in static maps
+type staticNames struct {
+	// names are user, bot and channel names indexed by their IDs
+	names map[string]string
+	// self are IDs of user and bot the names are looked up with
+	self [2]string
+}
+
+// userName returns the name of user with the given ID
+func (n *staticNames) userName(id string) (string, error) {
+	return n.names[id], nil
+}
+
+// botName returns the name of bot with the given ID
+func (n *staticNames) botName(id string) (string, error) {
+	return n.names[id], nil
+}
+
+// channelName returns the name of channel with the given ID
+func (n *staticNames) channelName(id string) (string, error) {
+	return n.names[id], nil
+}
+
+// identity returns IDs of user and bot the names are looked up with
+func (n *staticNames) identity() (string, string, error) {
+	return n.self[0], n.self[1], nil
+}
+
+// testNames returns static names of test users, bots and channels
+func testNames() *staticNames {
+	return &staticNames{
+		names: map[string]string{
+			"U2147483697": "nagios",
+			"B0AB12CDE":   "alertmanager",
+			"C024BE91L":   "alerts",
+		},
+		self: [2]string{"U0BOTUSER", "B0BOTBOT"},
+	}
+}
+
+// message returns message event posted by user in channel
+func message(channel, user, text string) *msgEvent {
+	return &msgEvent{Type: "message", Channel: channel, User: user, Text: text, TS: "1355517523.000005"}
+}
+
+func TestMsgMatcherMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		rules      []*Rule
+		channel    string
+		user       string
+		msg        string
+		resolve    string
+		ev         *msgEvent
+		wantAction string
+		wantLabels map[string]string
+		wantSong   *alertify.Song
+	}{
+		{
+			name:       "alert message",
+			channel:    "alerts",
+			user:       "nagios",
+			msg:        `^ALERT (?P<alertname>\w+)`,
+			resolve:    "^RESOLVED",
+			ev:         message("C024BE91L", "U2147483697", "ALERT DiskFull on db1"),
+			wantAction: ActionAlert,
+			wantLabels: map[string]string{"alertname": "DiskFull"},
+		},
+		{
+			name:       "resolve message",
+			channel:    "alerts",
+			user:       "nagios",
+			msg:        "DiskFull",
+			resolve:    "^RESOLVED",
+			ev:         message("C024BE91L", "U2147483697", "RESOLVED DiskFull on db1"),
+			wantAction: ActionSilence,
+		},
+		{
+			name:       "bot author",
+			channel:    "C024BE91L",
+			user:       "alertmanager",
+			msg:        "^ALERT",
+			ev:         &msgEvent{Type: "message", Channel: "C024BE91L", BotID: "B0AB12CDE", Text: "ALERT"},
+			wantAction: ActionAlert,
+			wantLabels: map[string]string{},
+		},
+		{
+			name:    "other author",
+			channel: "alerts",
+			user:    "nagios",
+			msg:     "^ALERT",
+			ev:      message("C024BE91L", "U0G9QF9C6", "ALERT DiskFull on db1"),
+		},
+		{
+			name: "own message",
+			msg:  "ALERT",
+			ev:   message("C024BE91L", "U0BOTUSER", "Alert played on Office: ALERT DiskFull"),
+		},
+		{
+			name: "own bot message",
+			msg:  "ALERT",
+			ev:   &msgEvent{Type: "message", Channel: "C024BE91L", User: "U0G9QF9C6", BotID: "B0BOTBOT", Text: "ALERT"},
+		},
+		{
+			name: "other event",
+			msg:  ".*",
+			ev:   &msgEvent{Type: "reaction_added", Channel: "C024BE91L", User: "U2147483697", Text: "ALERT"},
+		},
+		{
+			name: "empty message",
+			msg:  ".*",
+			ev:   message("C024BE91L", "U2147483697", ""),
+		},
+		{
+			name: "rules in order",
+			rules: []*Rule{
+				{Name: "ok", Include: "^OK", Action: ActionSilence},
+				{Name: "all", Include: ".*"},
+			},
+			ev:         message("C024BE91L", "U2147483697", "OK DiskFull"),
+			wantAction: ActionSilence,
+		},
+		{
+			name: "rule exclude",
+			rules: []*Rule{
+				{Name: "prod", Include: "^ALERT", Exclude: "staging"},
+			},
+			ev: message("C024BE91L", "U2147483697", "ALERT DiskFull in staging"),
+		},
+		{
+			name: "rule channels and authors",
+			rules: []*Rule{
+				{Name: "random", Channels: []string{"#random"}, Include: "^ALERT"},
+				{Name: "alerts", Channels: []string{"#alerts"}, Authors: []string{"Grafana"}, Include: "^ALERT", Severity: "critical"},
+			},
+			ev:         &msgEvent{Type: "message", Channel: "C024BE91L", BotID: "B0UNKNOWN", Username: "grafana", Text: "ALERT"},
+			wantAction: ActionAlert,
+			wantLabels: map[string]string{"rule": "alerts", "severity": "critical"},
+		},
+		{
+			name: "named fingerprint group",
+			rules: []*Rule{
+				{Include: `^ALERT (?P<alertname>\w+) \[(?P<fingerprint>\w+)\]`},
+			},
+			ev:         message("C024BE91L", "U2147483697", "ALERT DiskFull [a1b2c3]"),
+			wantAction: ActionAlert,
+			wantLabels: map[string]string{"alertname": "DiskFull", "fingerprint": "a1b2c3"},
+		},
+		{
+			name: "first group fingerprint",
+			rules: []*Rule{
+				{Include: `^ALERT \w+ \[(\w+)\]`},
+			},
+			ev:         message("C024BE91L", "U2147483697", "ALERT DiskFull [a1b2c3]"),
+			wantAction: ActionAlert,
+			wantLabels: map[string]string{"fingerprint": "a1b2c3"},
+		},
+		{
+			name: "song group",
+			rules: []*Rule{
+				{Include: `^play (?P<song>\S+)`, Song: &alertify.Song{Volume: 80}},
+			},
+			ev:         message("C024BE91L", "U2147483697", "play <https://open.spotify.com/track/7yTIKQzqRQfXDKKiPw3GJY|Siren>"),
+			wantAction: ActionAlert,
+			wantLabels: map[string]string{},
+			wantSong:   &alertify.Song{URI: "https://open.spotify.com/track/7yTIKQzqRQfXDKKiPw3GJY", Volume: 80},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newMsgMatcher("Test", tt.rules, tt.channel, tt.user, tt.msg, tt.resolve, newNameCache(testNames()))
+			if err != nil {
+				t.Fatalf("failed to create matcher: %v", err)
+			}
+
+			match, err := m.match(tt.ev)
+			if err != nil {
+				t.Fatalf("match() error = %v", err)
+			}
+
+			if match == nil {
+				if tt.wantAction != "" {
+					t.Fatalf("message not matched, want %s", tt.wantAction)
+				}
+				return
+			}
+
+			action, data := match.command()
+			if action != tt.wantAction {
+				t.Fatalf("action = %q, want %q", action, tt.wantAction)
+			}
+			if action != ActionAlert {
+				return
+			}
+
+			alert := data.(*alertify.Alert)
+			if !reflect.DeepEqual(alert.Labels, tt.wantLabels) {
+				t.Errorf("labels = %v, want %v", alert.Labels, tt.wantLabels)
+			}
+			if !reflect.DeepEqual(alert.Song, tt.wantSong) {
+				t.Errorf("song = %+v, want %+v", alert.Song, tt.wantSong)
+			}
+		})
+	}
+}
+
+func TestNewMsgMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []*Rule
+		msg     string
+		resolve string
+		wantErr bool
+	}{
+		{
+			name: "message and resolve",
+			msg:  "^ALERT", resolve: "^RESOLVED",
+		},
+		{
+			name:    "invalid message",
+			msg:     "^ALERT (",
+			wantErr: true,
+		},
+		{
+			name:    "invalid resolve",
+			msg:     "^ALERT",
+			resolve: "^RESOLVED [",
+			wantErr: true,
+		},
+		{
+			name:    "invalid exclude",
+			rules:   []*Rule{{Include: "^ALERT", Exclude: "("}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid action",
+			rules:   []*Rule{{Include: "^ALERT", Action: "ignore"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newMsgMatcher("Test", tt.rules, "", "", tt.msg, tt.resolve, newNameCache(nil))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newMsgMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMsgMatcherAct(t *testing.T) {
+	m, err := newMsgMatcher("Test", []*Rule{
+		{Include: `^RESOLVED \[(\w+)\]`, Action: ActionSilence},
+		{Include: `^ALL CLEAR$`, Action: ActionSilence},
+		{Include: `^ALERT \[(\w+)\]`},
+	}, "", "", "", "", newNameCache(nil))
+	if err != nil {
+		t.Fatalf("failed to create matcher: %v", err)
+	}
+
+	tests := []struct {
+		text    string
+		resp    interface{}
+		wantCmd string
+	}{
+		{text: "ALERT [db1]", wantCmd: ActionAlert},
+		{text: "ALERT [db2]", wantCmd: ActionAlert},
+		// the alert which failed to play doesn't keep the silence back
+		{text: "ALERT [db3]", resp: fmt.Errorf("no active device"), wantCmd: ActionAlert},
+		// db2 is still firing
+		{text: "RESOLVED [db1]"},
+		// alerts which have not been firing don't silence the alert
+		{text: "RESOLVED [db3]"},
+		{text: "RESOLVED [db9]"},
+		{text: "RESOLVED [db2]", wantCmd: ActionSilence},
+		{text: "RESOLVED [db2]"},
+		// resolve messages without fingerprint always silence the alert
+		{text: "ALERT [db4]", wantCmd: ActionAlert},
+		{text: "ALL CLEAR", wantCmd: ActionSilence},
+		{text: "RESOLVED [db4]"},
+	}
+
+	msgChan := make(chan *alertify.Msg)
+	for _, tt := range tests {
+		match, err := m.match(message("C024BE91L", "U2147483697", tt.text))
+		if err != nil || match == nil {
+			t.Fatalf("message %q not matched: %v", tt.text, err)
+		}
+
+		go func(resp interface{}) {
+			select {
+			case msg := <-msgChan:
+				msg.Resp <- resp
+			case <-time.After(100 * time.Millisecond):
+			}
+		}(tt.resp)
+
+		_, sent := m.act(msgChan, match)
+		if sent != (tt.wantCmd != "") {
+			t.Errorf("message %q sent command = %v, want %q", tt.text, sent, tt.wantCmd)
+		}
+	}
+}
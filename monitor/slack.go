@@ -3,8 +3,6 @@ package monitor
 import (
 	"fmt"
 	"log"
-	"regexp"
-	"strings"
 	"sync"
 
 	"github.com/milosgajdos/alertify"
@@ -15,12 +13,27 @@ import (
 type SlackConfig struct {
 	// APIKey is Slack API key
 	APIKey string
-	// Channel is the name of Slack channel
+	// Channel is the name or ID of Slack channel.
+	// Empty Channel matches messages in all channels the bot is member of.
 	Channel string
-	// User is the name of Slack user
+	// User is the name or ID of Slack user or bot which posts the messages.
+	// Empty User matches messages posted by anyone.
 	User string
 	// Msg is the message we are matching for
 	Msg string
+	// Resolve is the resolve message we are matching for. Resolve messages silence the alert.
+	// Its capture group can extract alert fingerprint; see FingerprintGroup.
+	Resolve string
+	// Rules configure messages the monitor acts upon. The first matching rule is applied.
+	// If there are no rules, messages posted by User in Channel which match Msg play the alert
+	// and the messages which match Resolve silence it.
+	Rules []*Rule
+	// Reply is either ReplyThread or ReplyReaction. The monitor then replies to the matched
+	// messages with the outcome of their actions. Empty Reply doesn't reply.
+	Reply string
+	// SilenceButton posts Acknowledge / Silence button in reply to the alert messages.
+	// The button is handled by SlackCommands monitor.
+	SilenceButton bool
 }
 
 // SlackMonitor is Slack API client which monitors messages
@@ -29,12 +42,14 @@ type SlackMonitor struct {
 	*slack.Client
 	// rtm is Slack RTM client
 	rtm *slack.RTM
-	// user is Slack bot name
+	// user is Slack user or bot name
 	user string
 	// channel is Slack channel
 	channel string
-	// msg is RegExp we are matching for
-	msg *regexp.Regexp
+	// matcher matches Slack messages
+	matcher *msgMatcher
+	// replier replies to matched Slack messages
+	replier *slackReplier
 	// doneChan stops Slack client
 	doneChan chan struct{}
 	// isRunning checks if bot is running
@@ -46,18 +61,20 @@ type SlackMonitor struct {
 // NewSlackMonitor creates new Slack message monitor
 func NewSlackMonitor(c *SlackConfig) (*SlackMonitor, error) {
 	api := slack.New(c.APIKey)
-	rtm := api.NewRTM()
-	// compile message regexp
-	msg, err := regexp.Compile(c.Msg)
+	// compile message rules; Slack names are looked up via Slack API and cached
+	matcher, err := newMsgMatcher("Slack", c.Rules, c.Channel, c.User, c.Msg, c.Resolve, newNameCache(&clientNames{api}))
+	if err != nil {
+		return nil, err
+	}
+	// replies are posted via Slack Web API
+	replier, err := newSlackReplier(newSlackAPI(""), c.APIKey, c.Reply, c.SilenceButton)
 	if err != nil {
 		return nil, err
 	}
-	// create notification channel
-	doneChan := make(chan struct{})
 	// mutex
 	m := &sync.Mutex{}
 
-	return &SlackMonitor{api, rtm, c.User, c.Channel, msg, doneChan, false, m}, nil
+	return &SlackMonitor{api, nil, c.User, c.Channel, matcher, replier, nil, false, m}, nil
 }
 
 // String returns the name of the monitor
@@ -75,17 +92,34 @@ func (s *SlackMonitor) User() string {
 	return s.user
 }
 
-// watchMessages listens to Slack messages and notifies alertify bot when a message regexp is matched
-func (s *SlackMonitor) watchMessages(alertChan chan struct{}, errChan chan error) {
+// watchMessages listens to Slack messages and notifies alertify bot when a message matches any rule
+// It stops watching when doneChan is closed.
+func (s *SlackMonitor) watchMessages(rtm *slack.RTM, alertChan chan *msgMatch, errChan chan error, doneChan chan struct{}) {
 	// monitor all slack messages
-	for msg := range s.rtm.IncomingEvents {
+	for {
+		var msg slack.RTMEvent
+		select {
+		case msg = <-rtm.IncomingEvents:
+		case <-doneChan:
+			return
+		}
+
 		switch ev := msg.Data.(type) {
 		case *slack.MessageEvent:
-			// if alertbot said something, send alert
-			user := s.rtm.GetInfo().User.Name
-			if strings.EqualFold(user, s.user) {
-				if s.msg.MatchString(ev.Text) {
-					alertChan <- struct{}{}
+			// if the message matches any rule, act upon it
+			match, err := s.matcher.match(newSlackEvent(ev))
+			if err != nil {
+				log.Printf("Could not match Slack message: %v", err)
+				if alertify.IsFatal(err) {
+					s.sendErr(errChan, doneChan, err)
+					return
+				}
+			}
+			if match != nil {
+				select {
+				case alertChan <- match:
+				case <-doneChan:
+					return
 				}
 			}
 
@@ -94,53 +128,68 @@ func (s *SlackMonitor) watchMessages(alertChan chan struct{}, errChan chan error
 
 		case *slack.RTMError:
 			// can do error.New(ev.Error())
-			errChan <- fmt.Errorf(ev.Error())
+			s.sendErr(errChan, doneChan, fmt.Errorf(ev.Error()))
+			return
 
 		case *slack.InvalidAuthEvent:
-			errChan <- fmt.Errorf("invalid Slack API credentials")
+			// restarting the monitor won't fix bad credentials
+			s.sendErr(errChan, doneChan, alertify.Fatal(fmt.Errorf("invalid Slack API credentials")))
+			return
 
 		default:
 		}
 	}
 }
 
-// MonitorAndAlert monitors channel and notifies alertify Bot when the preconfigured message regexp is matched
+// sendErr sends err to errChan unless doneChan is closed
+func (s *SlackMonitor) sendErr(errChan chan error, doneChan chan struct{}, err error) {
+	select {
+	case errChan <- err:
+	case <-doneChan:
+	}
+}
+
+// MonitorAndAlert monitors Slack messages and notifies alertify Bot when a message matches any of its rules
+// Every call opens a new RTM connection, so the monitor can be restarted after it fails.
 func (s *SlackMonitor) MonitorAndAlert(msgChan chan<- *alertify.Msg) error {
+	// create new RTM connection for this run
+	rtm := s.NewRTM()
+	doneChan := make(chan struct{})
+	s.Lock()
+	s.rtm = rtm
+	s.doneChan = doneChan
+	s.isRunning = true
+	s.Unlock()
+
 	// start RTM connection
-	go s.rtm.ManageConnection()
+	go rtm.ManageConnection()
 	// slack message notification channel
-	alertChan := make(chan struct{})
+	alertChan := make(chan *msgMatch)
 	// errChan is error channel
 	errChan := make(chan error)
+	// watchDone stops message watcher when this run finishes
+	watchDone := make(chan struct{})
+	defer close(watchDone)
 	// listen on incoming messages
-	go s.watchMessages(alertChan, errChan)
-
-	// bot response channel
-	respChan := make(chan interface{})
+	go s.watchMessages(rtm, alertChan, errChan, watchDone)
 
 	for {
-		// check if the slack monitor is running
-		s.Lock()
-		s.isRunning = true
-		s.Unlock()
 		select {
-		case <-alertChan:
-			log.Printf("Slack alert message match detected!")
-			// send message to alertify bot to play song
-			go func() {
-				msgChan <- &alertify.Msg{
-					Cmd:  "alert",
-					Data: nil,
-					Resp: respChan,
-				}
-			}()
-			if err := <-respChan; err != nil {
-				log.Printf("Could not play song: %v", err.(error))
+		case match := <-alertChan:
+			// send message to alertify bot to play or silence song
+			if resp, ok := s.matcher.act(msgChan, match); ok {
+				go s.replier.reply(match, resp)
 			}
-		case <-s.doneChan:
+		case <-doneChan:
 			// disconnect from RTM API
-			return s.rtm.Disconnect()
+			return rtm.Disconnect()
 		case err := <-errChan:
+			s.Lock()
+			s.isRunning = false
+			s.Unlock()
+			if dErr := rtm.Disconnect(); dErr != nil {
+				log.Printf("Error disconnecting from Slack RTM API: %v", dErr)
+			}
 			return err
 		}
 	}
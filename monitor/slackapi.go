@@ -0,0 +1,237 @@
+package monitor
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/milosgajdos/alertify"
+)
+
+const (
+	// DefaultSlackAPIURL is the default Slack Web API URL
+	DefaultSlackAPIURL = "https://slack.com/api/"
+	// SlackSignatureMaxAge is the maximum age of signed Slack requests
+	SlackSignatureMaxAge = 5 * time.Minute
+)
+
+// slackAPI calls Slack Web API methods
+type slackAPI struct {
+	// url is Slack Web API URL
+	url string
+	// client sends API requests
+	client *http.Client
+}
+
+// newSlackAPI creates new Slack Web API client and returns it
+func newSlackAPI(apiURL string) *slackAPI {
+	if apiURL == "" {
+		apiURL = DefaultSlackAPIURL
+	}
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+
+	return &slackAPI{
+		url:    apiURL,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// call calls Slack Web API method authenticated with token and decodes the response into v
+// Invalid credentials are reported as fatal errors.
+func (a *slackAPI) call(method, token string, params url.Values, v interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, a.url+method, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Slack API %s failed: %s", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Slack API %s failed: %s", method, resp.Status)
+	}
+
+	var body json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("invalid Slack API %s response: %s", method, err)
+	}
+
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("invalid Slack API %s response: %s", method, err)
+	}
+
+	if !status.OK {
+		err := fmt.Errorf("Slack API %s failed: %s", method, status.Error)
+		switch status.Error {
+		case "invalid_auth", "not_authed", "account_inactive", "token_revoked":
+			// restarting the monitor won't fix bad credentials
+			return alertify.Fatal(err)
+		}
+		return err
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+// userName returns the name of Slack user with the given ID
+func (a *slackAPI) userName(token, id string) (string, error) {
+	var resp struct {
+		User struct {
+			Name string `json:"name"`
+		} `json:"user"`
+	}
+
+	if err := a.call("users.info", token, url.Values{"user": {id}}, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.User.Name, nil
+}
+
+// botName returns the name of Slack bot with the given ID
+func (a *slackAPI) botName(token, id string) (string, error) {
+	var resp struct {
+		Bot struct {
+			Name string `json:"name"`
+		} `json:"bot"`
+	}
+
+	if err := a.call("bots.info", token, url.Values{"bot": {id}}, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.Bot.Name, nil
+}
+
+// channelName returns the name of Slack channel with the given ID
+func (a *slackAPI) channelName(token, id string) (string, error) {
+	var resp struct {
+		Channel struct {
+			Name string `json:"name"`
+		} `json:"channel"`
+	}
+
+	if err := a.call("conversations.info", token, url.Values{"channel": {id}}, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.Channel.Name, nil
+}
+
+// authTest returns IDs of Slack user and bot the token belongs to
+func (a *slackAPI) authTest(token string) (string, string, error) {
+	var resp struct {
+		UserID string `json:"user_id"`
+		BotID  string `json:"bot_id"`
+	}
+
+	if err := a.call("auth.test", token, nil, &resp); err != nil {
+		return "", "", err
+	}
+
+	return resp.UserID, resp.BotID, nil
+}
+
+// slackMessage is Slack message posted via Slack API or response URL
+type slackMessage struct {
+	Channel         string        `json:"channel,omitempty"`
+	ThreadTS        string        `json:"thread_ts,omitempty"`
+	Text            string        `json:"text"`
+	Blocks          []interface{} `json:"blocks,omitempty"`
+	ResponseType    string        `json:"response_type,omitempty"`
+	ReplaceOriginal bool          `json:"replace_original,omitempty"`
+}
+
+// postMessage posts message msg to Slack channel
+func (a *slackAPI) postMessage(token string, msg *slackMessage) error {
+	params := url.Values{
+		"channel": {msg.Channel},
+		"text":    {msg.Text},
+	}
+	if msg.ThreadTS != "" {
+		params.Set("thread_ts", msg.ThreadTS)
+	}
+	if len(msg.Blocks) > 0 {
+		blocks, err := json.Marshal(msg.Blocks)
+		if err != nil {
+			return err
+		}
+		params.Set("blocks", string(blocks))
+	}
+
+	return a.call("chat.postMessage", token, params, nil)
+}
+
+// respond posts message msg to Slack response URL
+func (a *slackAPI) respond(responseURL string, msg *slackMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.client.Post(responseURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Slack response failed: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Slack response failed: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// verifySlackSignature verifies Slack request signature of body
+// See https://api.slack.com/authentication/verifying-requests-from-slack
+func verifySlackSignature(secret string, header http.Header, body []byte) error {
+	ts := header.Get("X-Slack-Request-Timestamp")
+	sig := header.Get("X-Slack-Signature")
+	if ts == "" || sig == "" {
+		return fmt.Errorf("missing Slack request signature")
+	}
+
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid Slack request timestamp: %s", ts)
+	}
+
+	age := time.Since(time.Unix(sec, 0))
+	if age > SlackSignatureMaxAge || age < -SlackSignatureMaxAge {
+		return fmt.Errorf("Slack request timestamp too old: %s", ts)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:", ts)
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return fmt.Errorf("invalid Slack request signature")
+	}
+
+	return nil
+}
@@ -0,0 +1,314 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/milosgajdos/alertify"
+)
+
+const (
+	// DefaultSlackCommandsAddr is the default address of Slack commands endpoints
+	DefaultSlackCommandsAddr = ":3001"
+	// DefaultSlackCommandsPath is the default path of Slack slash commands endpoint
+	DefaultSlackCommandsPath = "/slack/commands"
+	// DefaultSlackInteractivePath is the default path of Slack interactivity endpoint
+	DefaultSlackInteractivePath = "/slack/interactive"
+	// slackCommandsUsage describes Slack slash command usage
+	slackCommandsUsage = "Usage: `/alertify silence`, `/alertify status` or `/alertify play [song]`"
+)
+
+// SlackCommandsConfig configures Slack commands monitor
+type SlackCommandsConfig struct {
+	// SigningSecret verifies requests sent by Slack
+	SigningSecret string
+	// Addr is the address Slack endpoints listen on. It defaults to DefaultSlackCommandsAddr.
+	Addr string
+	// CommandsPath is slash commands endpoint path. It defaults to DefaultSlackCommandsPath.
+	CommandsPath string
+	// InteractivePath is interactivity endpoint path. It defaults to DefaultSlackInteractivePath.
+	InteractivePath string
+}
+
+// SlackCommands controls alertify Bot with Slack slash commands and interactive buttons
+type SlackCommands struct {
+	// api responds to Slack commands
+	api *slackAPI
+	// signingSecret verifies Slack requests
+	signingSecret string
+	// addr is Slack endpoints address
+	addr string
+	// commandsPath is slash commands endpoint path
+	commandsPath string
+	// interactivePath is interactivity endpoint path
+	interactivePath string
+	// doneChan stops the monitor
+	doneChan chan struct{}
+	// isRunning checks if monitor is running
+	isRunning bool
+	// mutex
+	*sync.Mutex
+}
+
+// slackInteraction is Slack interactivity request payload
+type slackInteraction struct {
+	Type string `json:"type"`
+	User struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"user"`
+	ResponseURL string `json:"response_url"`
+	Actions     []struct {
+		ActionID string `json:"action_id"`
+	} `json:"actions"`
+}
+
+// NewSlackCommands creates new Slack commands monitor
+// It returns error if the signing secret is not configured.
+func NewSlackCommands(c *SlackCommandsConfig) (*SlackCommands, error) {
+	if c.SigningSecret == "" {
+		return nil, fmt.Errorf("missing Slack signing secret")
+	}
+
+	addr := c.Addr
+	if addr == "" {
+		addr = DefaultSlackCommandsAddr
+	}
+
+	commandsPath := c.CommandsPath
+	if commandsPath == "" {
+		commandsPath = DefaultSlackCommandsPath
+	}
+
+	interactivePath := c.InteractivePath
+	if interactivePath == "" {
+		interactivePath = DefaultSlackInteractivePath
+	}
+
+	return &SlackCommands{
+		api:             newSlackAPI(""),
+		signingSecret:   c.SigningSecret,
+		addr:            addr,
+		commandsPath:    commandsPath,
+		interactivePath: interactivePath,
+		Mutex:           &sync.Mutex{},
+	}, nil
+}
+
+// String returns the name of the monitor
+func (s *SlackCommands) String() string {
+	return "Slack Commands"
+}
+
+// readRequest reads Slack request body and verifies its signature
+func (s *SlackCommands) readRequest(w http.ResponseWriter, r *http.Request) (url.Values, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxSlackEventSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	if err := verifySlackSignature(s.signingSecret, r.Header, body); err != nil {
+		log.Printf("Rejecting Slack request: %s", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	return form, true
+}
+
+// handleCommands returns slash commands endpoint handler
+// Commands are acknowledged straight away and their results are posted to the command response URL.
+func (s *SlackCommands) handleCommands(msgChan chan<- *alertify.Msg) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		form, ok := s.readRequest(w, r)
+		if !ok {
+			return
+		}
+
+		args := strings.Fields(form.Get("text"))
+		if len(args) == 0 || (args[0] != "silence" && args[0] != "status" && args[0] != "play") {
+			writeSlackMessage(w, &slackMessage{Text: slackCommandsUsage})
+			return
+		}
+
+		log.Printf("Slack command %s %s received from %s", form.Get("command"), args[0], form.Get("user_name"))
+
+		w.WriteHeader(http.StatusOK)
+
+		go func() {
+			msg := s.command(msgChan, args, form.Get("user_id"))
+			if err := s.api.respond(form.Get("response_url"), msg); err != nil {
+				log.Printf("Could not respond to Slack command: %v", err)
+			}
+		}()
+	}
+}
+
+// command sends slash command args to bot and returns Slack message with the result
+func (s *SlackCommands) command(msgChan chan<- *alertify.Msg, args []string, user string) *slackMessage {
+	switch args[0] {
+	case "silence":
+		if err := alertify.RespError(sendCommand(msgChan, "silence", nil)); err != nil {
+			return &slackMessage{Text: fmt.Sprintf("Could not silence alert: %v", err)}
+		}
+		return &slackMessage{Text: fmt.Sprintf("Alert silenced by <@%s>", user), ResponseType: "in_channel"}
+	case "status":
+		statuses, ok := sendCommand(msgChan, "monitors", nil).([]*alertify.MonitorStatus)
+		if !ok {
+			return &slackMessage{Text: "Could not get monitors status"}
+		}
+		return &slackMessage{Text: monitorsText(statuses)}
+	}
+
+	var data interface{}
+	if song := strings.Join(args[1:], " "); song != "" {
+		data = song
+	}
+
+	resp := sendCommand(msgChan, "alert", data)
+	if err := alertify.RespError(resp); err != nil {
+		return &slackMessage{Text: fmt.Sprintf("Could not play song: %v", err)}
+	}
+
+	text := fmt.Sprintf("Alert played by <@%s>", user)
+	if result, ok := resp.(*alertify.AlertResult); ok {
+		text += ": " + result.String()
+	}
+
+	return &slackMessage{Text: text, ResponseType: "in_channel"}
+}
+
+// monitorsText returns Slack message text describing monitors statuses
+func monitorsText(statuses []*alertify.MonitorStatus) string {
+	if len(statuses) == 0 {
+		return "No monitors registered"
+	}
+
+	lines := make([]string, len(statuses))
+	for i, st := range statuses {
+		line := fmt.Sprintf("• *%s* (%s): %s", st.Name, st.Monitor, st.State)
+		if st.Restarts > 0 {
+			line += fmt.Sprintf(", %d restarts", st.Restarts)
+		}
+		if st.Error != "" {
+			line += ", last error: " + st.Error
+		}
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// handleInteractive returns interactivity endpoint handler
+// Silence button silences the alert and replaces the message the button was posted in.
+func (s *SlackCommands) handleInteractive(msgChan chan<- *alertify.Msg) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		form, ok := s.readRequest(w, r)
+		if !ok {
+			return
+		}
+
+		payload := new(slackInteraction)
+		if err := json.Unmarshal([]byte(form.Get("payload")), payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		if payload.Type != "block_actions" {
+			return
+		}
+
+		for _, action := range payload.Actions {
+			if action.ActionID != SilenceActionID {
+				continue
+			}
+
+			log.Printf("Slack silence button pressed by %s", payload.User.Name)
+
+			go func() {
+				msg := &slackMessage{
+					Text:            fmt.Sprintf("Alert silenced by <@%s>", payload.User.ID),
+					ReplaceOriginal: true,
+				}
+				if err := alertify.RespError(sendCommand(msgChan, "silence", nil)); err != nil {
+					msg = &slackMessage{Text: fmt.Sprintf("Could not silence alert: %v", err)}
+				}
+				if err := s.api.respond(payload.ResponseURL, msg); err != nil {
+					log.Printf("Could not respond to Slack action: %v", err)
+				}
+			}()
+			return
+		}
+	}
+}
+
+// writeSlackMessage writes Slack message msg to HTTP response
+func writeSlackMessage(w http.ResponseWriter, msg *slackMessage) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		log.Printf("Could not write Slack response: %v", err)
+	}
+}
+
+// MonitorAndAlert serves Slack commands endpoints and sends the received commands to alertify Bot
+// Every call starts new HTTP server, so the monitor can be restarted after it fails.
+func (s *SlackCommands) MonitorAndAlert(msgChan chan<- *alertify.Msg) error {
+	doneChan := make(chan struct{})
+	s.Lock()
+	s.doneChan = doneChan
+	s.isRunning = true
+	s.Unlock()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.commandsPath, s.handleCommands(msgChan))
+	mux.HandleFunc(s.interactivePath, s.handleInteractive(msgChan))
+
+	srv := &http.Server{Addr: s.addr, Handler: mux}
+	errChan := make(chan error, 1)
+
+	go func() {
+		log.Printf("Listening for Slack commands on %s", s.addr)
+		errChan <- srv.ListenAndServe()
+	}()
+
+	select {
+	case <-doneChan:
+		return srv.Close()
+	case err := <-errChan:
+		s.Lock()
+		s.isRunning = false
+		s.Unlock()
+		return err
+	}
+}
+
+// Stop stops Slack commands monitor
+func (s *SlackCommands) Stop() {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.isRunning {
+		close(s.doneChan)
+		s.isRunning = false
+	}
+}
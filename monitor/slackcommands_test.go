@@ -0,0 +1,338 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/milosgajdos/alertify"
+)
+
+// slackResponder is fake Slack response URL which records posted messages
+type slackResponder struct {
+	// srv serves the response URL
+	srv *httptest.Server
+	// msgChan receives posted messages
+	msgChan chan *slackMessage
+}
+
+// newSlackResponder starts fake Slack response URL server and returns it
+func newSlackResponder(t *testing.T) *slackResponder {
+	r := &slackResponder{msgChan: make(chan *slackMessage, 1)}
+	r.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		msg := new(slackMessage)
+		if err := json.NewDecoder(req.Body).Decode(msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.msgChan <- msg
+	}))
+	t.Cleanup(r.srv.Close)
+
+	return r
+}
+
+// message returns the message posted to the response URL
+func (r *slackResponder) message(t *testing.T) *slackMessage {
+	select {
+	case msg := <-r.msgChan:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message posted to response URL")
+	}
+
+	return nil
+}
+
+// fakeBot responds to a single command with resp and sends it to cmdChan
+func fakeBot(msgChan <-chan *alertify.Msg, resp interface{}) <-chan *alertify.Msg {
+	cmdChan := make(chan *alertify.Msg, 1)
+	go func() {
+		select {
+		case msg := <-msgChan:
+			msg.Resp <- resp
+			cmdChan <- msg
+		case <-time.After(5 * time.Second):
+		}
+	}()
+
+	return cmdChan
+}
+
+// serveSlackRequest serves signed Slack request with form body by handler
+func serveSlackRequest(t *testing.T, handler http.HandlerFunc, method, secret string, form url.Values) *httptest.ResponseRecorder {
+	body := form.Encode()
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	signSlackRequest(req, secret, []byte(body))
+
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	return w
+}
+
+// newTestSlackCommands creates Slack commands monitor verifying requests with testSigningSecret
+func newTestSlackCommands(t *testing.T) *SlackCommands {
+	s, err := NewSlackCommands(&SlackCommandsConfig{SigningSecret: testSigningSecret})
+	if err != nil {
+		t.Fatalf("failed to create Slack commands monitor: %v", err)
+	}
+
+	return s
+}
+
+func TestSlackCommandsHandler(t *testing.T) {
+	played := &alertify.AlertResult{
+		Song:    "spotify:track:7yTIKQzqRQfXDKKiPw3GJY",
+		Devices: []*alertify.DeviceResult{{Device: "ceres"}},
+	}
+	statuses := []*alertify.MonitorStatus{{Name: "alerts", Monitor: "Slack Events", State: "running", Restarts: 2}}
+
+	tests := []struct {
+		name          string
+		method        string
+		secret        string
+		text          string
+		resp          interface{}
+		wantCode      int
+		wantUsage     bool
+		wantCmd       string
+		wantData      interface{}
+		wantText      string
+		wantInChannel bool
+	}{
+		{
+			name:          "silence",
+			text:          "silence",
+			wantCode:      http.StatusOK,
+			wantCmd:       "silence",
+			wantText:      "Alert silenced by <@U2147483697>",
+			wantInChannel: true,
+		},
+		{
+			name:     "silence failed",
+			text:     "silence",
+			resp:     fmt.Errorf("no active device"),
+			wantCode: http.StatusOK,
+			wantCmd:  "silence",
+			wantText: "Could not silence alert: no active device",
+		},
+		{
+			name:     "status",
+			text:     "status",
+			resp:     statuses,
+			wantCode: http.StatusOK,
+			wantCmd:  "monitors",
+			wantText: "• *alerts* (Slack Events): running, 2 restarts",
+		},
+		{
+			name:          "play default song",
+			text:          "play",
+			resp:          played,
+			wantCode:      http.StatusOK,
+			wantCmd:       "alert",
+			wantText:      "Alert played by <@U2147483697>: played on ceres",
+			wantInChannel: true,
+		},
+		{
+			name:          "play song",
+			text:          `play  artist:"John Denver"   track:"Country Roads"`,
+			resp:          played,
+			wantCode:      http.StatusOK,
+			wantCmd:       "alert",
+			wantData:      `artist:"John Denver" track:"Country Roads"`,
+			wantText:      "Alert played by <@U2147483697>: played on ceres",
+			wantInChannel: true,
+		},
+		{
+			name:     "play failed",
+			text:     "play",
+			resp:     fmt.Errorf("no active device"),
+			wantCode: http.StatusOK,
+			wantCmd:  "alert",
+			wantText: "Could not play song: no active device",
+		},
+		{
+			name:      "no command",
+			wantCode:  http.StatusOK,
+			wantUsage: true,
+		},
+		{
+			name:      "unknown command",
+			text:      "dance",
+			wantCode:  http.StatusOK,
+			wantUsage: true,
+		},
+		{
+			name:     "invalid signature",
+			text:     "silence",
+			secret:   "invalid",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "invalid method",
+			method:   http.MethodGet,
+			text:     "silence",
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSlackCommands(t)
+			responder := newSlackResponder(t)
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			secret := tt.secret
+			if secret == "" {
+				secret = testSigningSecret
+			}
+
+			msgChan := make(chan *alertify.Msg)
+			cmdChan := fakeBot(msgChan, tt.resp)
+
+			w := serveSlackRequest(t, s.handleCommands(msgChan), method, secret, url.Values{
+				"command":      {"/alertify"},
+				"text":         {tt.text},
+				"user_id":      {"U2147483697"},
+				"user_name":    {"steve"},
+				"response_url": {responder.srv.URL},
+			})
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantCode)
+			}
+			if got := strings.Contains(w.Body.String(), slackCommandsUsage); got != tt.wantUsage {
+				t.Errorf("usage in response = %v, want %v: %s", got, tt.wantUsage, w.Body.String())
+			}
+
+			if tt.wantCmd == "" {
+				select {
+				case msg := <-cmdChan:
+					t.Fatalf("unexpected %s command sent to bot", msg.Cmd)
+				case <-time.After(100 * time.Millisecond):
+				}
+				return
+			}
+
+			msg := responder.message(t)
+			cmd := <-cmdChan
+
+			if cmd.Cmd != tt.wantCmd || cmd.Data != tt.wantData {
+				t.Errorf("command = %s %v, want %s %v", cmd.Cmd, cmd.Data, tt.wantCmd, tt.wantData)
+			}
+			if msg.Text != tt.wantText {
+				t.Errorf("response text = %q, want %q", msg.Text, tt.wantText)
+			}
+			if got := msg.ResponseType == "in_channel"; got != tt.wantInChannel {
+				t.Errorf("response posted in channel = %v, want %v", got, tt.wantInChannel)
+			}
+		})
+	}
+}
+
+func TestSlackInteractiveHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		secret      string
+		payload     string
+		resp        interface{}
+		wantCode    int
+		wantSilence bool
+		wantText    string
+		wantReplace bool
+	}{
+		{
+			name:        "silence button",
+			payload:     `{"type":"block_actions","user":{"id":"U2147483697","name":"steve"},"actions":[{"action_id":"alertify_silence"}]}`,
+			wantCode:    http.StatusOK,
+			wantSilence: true,
+			wantText:    "Alert silenced by <@U2147483697>",
+			wantReplace: true,
+		},
+		{
+			name:        "silence failed",
+			payload:     `{"type":"block_actions","user":{"id":"U2147483697","name":"steve"},"actions":[{"action_id":"alertify_silence"}]}`,
+			resp:        fmt.Errorf("no active device"),
+			wantCode:    http.StatusOK,
+			wantSilence: true,
+			wantText:    "Could not silence alert: no active device",
+		},
+		{
+			name:     "other action",
+			payload:  `{"type":"block_actions","user":{"id":"U2147483697","name":"steve"},"actions":[{"action_id":"approve"}]}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "other interaction",
+			payload:  `{"type":"view_submission","user":{"id":"U2147483697","name":"steve"}}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "invalid payload",
+			payload:  `{"type":`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid signature",
+			secret:   "invalid",
+			payload:  `{"type":"block_actions","user":{"id":"U2147483697","name":"steve"},"actions":[{"action_id":"alertify_silence"}]}`,
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSlackCommands(t)
+			responder := newSlackResponder(t)
+
+			secret := tt.secret
+			if secret == "" {
+				secret = testSigningSecret
+			}
+
+			// response URL is only known once the fake responder is running
+			payload := strings.Replace(tt.payload, `"type"`, fmt.Sprintf(`"response_url":%q,"type"`, responder.srv.URL), 1)
+
+			msgChan := make(chan *alertify.Msg)
+			cmdChan := fakeBot(msgChan, tt.resp)
+
+			w := serveSlackRequest(t, s.handleInteractive(msgChan), http.MethodPost, secret, url.Values{
+				"payload": {payload},
+			})
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantCode)
+			}
+
+			if !tt.wantSilence {
+				select {
+				case msg := <-cmdChan:
+					t.Fatalf("unexpected %s command sent to bot", msg.Cmd)
+				case <-time.After(100 * time.Millisecond):
+				}
+				return
+			}
+
+			msg := responder.message(t)
+			if cmd := <-cmdChan; cmd.Cmd != "silence" {
+				t.Errorf("command = %s, want silence", cmd.Cmd)
+			}
+			if msg.Text != tt.wantText {
+				t.Errorf("response text = %q, want %q", msg.Text, tt.wantText)
+			}
+			if msg.ReplaceOriginal != tt.wantReplace {
+				t.Errorf("response replaces original message = %v, want %v", msg.ReplaceOriginal, tt.wantReplace)
+			}
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/milosgajdos/alertify"
+)
+
+const (
+	// DefaultSlackEventsAddr is the default address of Slack Events API endpoint
+	DefaultSlackEventsAddr = ":3000"
+	// DefaultSlackEventsPath is the default path of Slack Events API endpoint
+	DefaultSlackEventsPath = "/slack/events"
+	// maxSlackEventSize is the maximum size of Slack Events API request body
+	maxSlackEventSize = 1 << 20
+	// maxSlackEventIDs is the number of recently received event IDs kept to drop redelivered events
+	maxSlackEventIDs = 1000
+)
+
+// SlackEventsConfig configures Slack Events API monitor
+type SlackEventsConfig struct {
+	// APIKey is Slack bot token used to look up user, bot and channel names.
+	// Without APIKey, Channel and User only match channel, user and bot IDs and bot usernames.
+	APIKey string
+	// SigningSecret verifies Events API requests sent by Slack
+	SigningSecret string
+	// Addr is the address Events API endpoint listens on. It defaults to DefaultSlackEventsAddr.
+	Addr string
+	// Path is Events API endpoint path. It defaults to DefaultSlackEventsPath.
+	Path string
+	// AppToken is Slack app-level token. If set, events are received via Socket Mode
+	// instead of Events API endpoint.
+	AppToken string
+	// APIURL is Slack Web API URL. It defaults to DefaultSlackAPIURL.
+	APIURL string
+	// Channel is the name or ID of Slack channel
+	Channel string
+	// User is the name or ID of Slack user or bot
+	User string
+	// Msg is the message we are matching for
+	Msg string
+	// Resolve is the resolve message we are matching for. Resolve messages silence the alert.
+	// Its capture group can extract alert fingerprint; see FingerprintGroup.
+	Resolve string
+	// Rules configure messages the monitor acts upon. The first matching rule is applied.
+	// If there are no rules, messages posted by User in Channel which match Msg play the alert
+	// and the messages which match Resolve silence it.
+	Rules []*Rule
+	// Reply is either ReplyThread or ReplyReaction. The monitor then replies to the matched
+	// messages with the outcome of their actions. Empty Reply doesn't reply.
+	Reply string
+	// SilenceButton posts Acknowledge / Silence button in reply to the alert messages.
+	// The button is handled by SlackCommands monitor.
+	SilenceButton bool
+}
+
+// SlackEventsMonitor monitors Slack messages received via Events API or Socket Mode
+type SlackEventsMonitor struct {
+	// api is Slack Web API client
+	api *slackAPI
+	// signingSecret verifies Events API requests
+	signingSecret string
+	// appToken is Slack app-level token
+	appToken string
+	// addr is Events API endpoint address
+	addr string
+	// path is Events API endpoint path
+	path string
+	// user is Slack user name or ID
+	user string
+	// channel is Slack channel name or ID
+	channel string
+	// matcher matches Slack messages
+	matcher *msgMatcher
+	// replier replies to matched Slack messages
+	replier *slackReplier
+	// eventIDs are IDs of recently received events
+	eventIDs map[string]bool
+	// eventOrder contains eventIDs in the order they were received
+	eventOrder []string
+	// doneChan stops the monitor
+	doneChan chan struct{}
+	// isRunning checks if monitor is running
+	isRunning bool
+	// mutex
+	*sync.Mutex
+}
+
+// slackEventCallback is Slack Events API request
+type slackEventCallback struct {
+	Type      string      `json:"type"`
+	Challenge string      `json:"challenge"`
+	EventID   string      `json:"event_id"`
+	Event     *slackEvent `json:"event"`
+}
+
+// socketEnvelope is Slack Socket Mode message
+type socketEnvelope struct {
+	EnvelopeID string          `json:"envelope_id"`
+	Type       string          `json:"type"`
+	Reason     string          `json:"reason"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// NewSlackEventsMonitor creates new Slack Events API monitor
+// It returns error if neither signing secret nor app token is configured.
+func NewSlackEventsMonitor(c *SlackEventsConfig) (*SlackEventsMonitor, error) {
+	if c.SigningSecret == "" && c.AppToken == "" {
+		return nil, fmt.Errorf("missing Slack signing secret or app token")
+	}
+
+	api := newSlackAPI(c.APIURL)
+
+	var names msgNames
+	if c.APIKey != "" {
+		names = &apiNames{api: api, token: c.APIKey}
+	}
+
+	replier, err := newSlackReplier(api, c.APIKey, c.Reply, c.SilenceButton)
+	if err != nil {
+		return nil, err
+	}
+
+	matcher, err := newMsgMatcher("Slack", c.Rules, c.Channel, c.User, c.Msg, c.Resolve, newNameCache(names))
+	if err != nil {
+		return nil, err
+	}
+
+	addr := c.Addr
+	if addr == "" {
+		addr = DefaultSlackEventsAddr
+	}
+
+	path := c.Path
+	if path == "" {
+		path = DefaultSlackEventsPath
+	}
+
+	return &SlackEventsMonitor{
+		api:           api,
+		signingSecret: c.SigningSecret,
+		appToken:      c.AppToken,
+		addr:          addr,
+		path:          path,
+		user:          c.User,
+		channel:       c.Channel,
+		matcher:       matcher,
+		replier:       replier,
+		eventIDs:      make(map[string]bool),
+		Mutex:         &sync.Mutex{},
+	}, nil
+}
+
+// String returns the name of the monitor
+func (s *SlackEventsMonitor) String() string {
+	return "Slack Events Monitor"
+}
+
+// Channel returns the name of the Slack channel which we monitor
+func (s *SlackEventsMonitor) Channel() string {
+	return s.channel
+}
+
+// User returns slack user name whose message we monitor
+func (s *SlackEventsMonitor) User() string {
+	return s.user
+}
+
+// seen records event ID and returns true if the event has already been received
+// Slack redelivers the events which haven't been acknowledged in time.
+func (s *SlackEventsMonitor) seen(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.eventIDs[id] {
+		return true
+	}
+
+	s.eventIDs[id] = true
+	s.eventOrder = append(s.eventOrder, id)
+	if len(s.eventOrder) > maxSlackEventIDs {
+		delete(s.eventIDs, s.eventOrder[0])
+		s.eventOrder = s.eventOrder[1:]
+	}
+
+	return false
+}
+
+// dispatch sends message event to eventChan without blocking
+// Redelivered events are dropped and so are the events received when eventChan is full.
+func (s *SlackEventsMonitor) dispatch(cb *slackEventCallback, eventChan chan *msgEvent) {
+	if cb.Type != "event_callback" || cb.Event == nil {
+		return
+	}
+
+	if s.seen(cb.EventID) {
+		log.Printf("Dropping redelivered Slack event %s", cb.EventID)
+		return
+	}
+
+	select {
+	case eventChan <- cb.Event.msgEvent():
+	default:
+		log.Printf("Dropping Slack event %s: too many pending events", cb.EventID)
+	}
+}
+
+// handleEvents returns Events API endpoint handler
+// Events are acknowledged straight away, Slack retries the events which aren't acknowledged within 3 seconds.
+func (s *SlackEventsMonitor) handleEvents(eventChan chan *msgEvent) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxSlackEventSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := verifySlackSignature(s.signingSecret, r.Header, body); err != nil {
+			log.Printf("Rejecting Slack event: %s", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		cb := new(slackEventCallback)
+		if err := json.Unmarshal(body, cb); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if cb.Type == "url_verification" {
+			w.Header().Set("Content-Type", "text/plain")
+			if _, err := w.Write([]byte(cb.Challenge)); err != nil {
+				log.Printf("Failed to respond to Slack URL verification: %s", err)
+			}
+			return
+		}
+
+		if retry := r.Header.Get("X-Slack-Retry-Num"); retry != "" {
+			log.Printf("Slack event %s retry %s: %s", cb.EventID, retry, r.Header.Get("X-Slack-Retry-Reason"))
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		s.dispatch(cb, eventChan)
+	}
+}
+
+// serveEvents serves Events API endpoint until doneChan is closed
+func (s *SlackEventsMonitor) serveEvents(eventChan chan *msgEvent, errChan chan error, doneChan chan struct{}) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.path, s.handleEvents(eventChan))
+
+	srv := &http.Server{Addr: s.addr, Handler: mux}
+	go func() {
+		<-doneChan
+		if err := srv.Close(); err != nil {
+			log.Printf("Failed to stop Slack events endpoint: %s", err)
+		}
+	}()
+
+	log.Printf("Listening for Slack events on %s%s", s.addr, s.path)
+
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		s.sendErr(errChan, doneChan, err)
+	}
+}
+
+// socketMode receives events via Socket Mode until doneChan is closed
+// It reconnects when Slack asks the client to disconnect.
+func (s *SlackEventsMonitor) socketMode(eventChan chan *msgEvent, errChan chan error, doneChan chan struct{}) {
+	for {
+		err := s.socketConnection(eventChan, doneChan)
+		select {
+		case <-doneChan:
+			return
+		default:
+		}
+
+		if err != nil {
+			s.sendErr(errChan, doneChan, err)
+			return
+		}
+
+		log.Printf("Reconnecting to Slack Socket Mode")
+	}
+}
+
+// socketConnection opens Socket Mode connection and receives events until Slack disconnects
+func (s *SlackEventsMonitor) socketConnection(eventChan chan *msgEvent, doneChan chan struct{}) error {
+	var open struct {
+		URL string `json:"url"`
+	}
+
+	if err := s.api.call("apps.connections.open", s.appToken, nil, &open); err != nil {
+		return err
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(open.URL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Slack Socket Mode: %s", err)
+	}
+
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	go func() {
+		select {
+		case <-doneChan:
+		case <-closeChan:
+		}
+		conn.Close()
+	}()
+
+	for {
+		env := new(socketEnvelope)
+		if err := conn.ReadJSON(env); err != nil {
+			return fmt.Errorf("Slack Socket Mode connection failed: %s", err)
+		}
+
+		// events must be acknowledged otherwise Slack keeps retrying them
+		if env.EnvelopeID != "" {
+			if err := conn.WriteJSON(map[string]string{"envelope_id": env.EnvelopeID}); err != nil {
+				return fmt.Errorf("failed to acknowledge Slack event: %s", err)
+			}
+		}
+
+		switch env.Type {
+		case "hello":
+			log.Printf("Connected to Slack Socket Mode")
+		case "disconnect":
+			log.Printf("Slack Socket Mode disconnect requested: %s", env.Reason)
+			return nil
+		case "events_api":
+			cb := new(slackEventCallback)
+			if err := json.Unmarshal(env.Payload, cb); err != nil {
+				log.Printf("Invalid Slack event: %s", err)
+				continue
+			}
+			s.dispatch(cb, eventChan)
+		}
+	}
+}
+
+// sendErr sends err to errChan unless doneChan is closed
+func (s *SlackEventsMonitor) sendErr(errChan chan error, doneChan chan struct{}, err error) {
+	select {
+	case errChan <- err:
+	case <-doneChan:
+	}
+}
+
+// MonitorAndAlert monitors Slack messages and notifies alertify Bot when a message matches any of its rules
+// Every call starts a new Events API endpoint or Socket Mode connection, so the monitor can be restarted after it fails.
+func (s *SlackEventsMonitor) MonitorAndAlert(msgChan chan<- *alertify.Msg) error {
+	doneChan := make(chan struct{})
+	s.Lock()
+	s.doneChan = doneChan
+	s.isRunning = true
+	s.Unlock()
+
+	// eventChan receives Slack message events
+	eventChan := make(chan *msgEvent, 16)
+	// errChan is error channel
+	errChan := make(chan error)
+	// watchDone stops event receivers when this run finishes
+	watchDone := make(chan struct{})
+	defer close(watchDone)
+
+	if s.appToken != "" {
+		go s.socketMode(eventChan, errChan, watchDone)
+	} else {
+		go s.serveEvents(eventChan, errChan, watchDone)
+	}
+
+	for {
+		select {
+		case ev := <-eventChan:
+			match, err := s.matcher.match(ev)
+			if err != nil {
+				log.Printf("Could not match Slack message: %v", err)
+				if alertify.IsFatal(err) {
+					s.stopped()
+					return err
+				}
+			}
+			if match == nil {
+				continue
+			}
+			// send message to alertify bot to play or silence song
+			if resp, ok := s.matcher.act(msgChan, match); ok {
+				go s.replier.reply(match, resp)
+			}
+		case <-doneChan:
+			return nil
+		case err := <-errChan:
+			s.stopped()
+			return err
+		}
+	}
+}
+
+// stopped marks the monitor as not running
+func (s *SlackEventsMonitor) stopped() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.isRunning = false
+}
+
+// Stop stops Slack event monitor
+func (s *SlackEventsMonitor) Stop() {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.isRunning {
+		close(s.doneChan)
+		s.isRunning = false
+	}
+}
@@ -0,0 +1,350 @@
+package monitor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/milosgajdos/alertify"
+)
+
+const testSigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+// signSlackRequest signs Slack request with body the way Slack does
+func signSlackRequest(r *http.Request, secret string, body []byte) {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+
+	r.Header.Set("X-Slack-Request-Timestamp", ts)
+	r.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+// messageCallback returns Events API callback of message event with the given ID and text
+func messageCallback(id, text string) string {
+	return fmt.Sprintf(`{"type":"event_callback","event_id":%q,"event":`+
+		`{"type":"message","channel":"C024BE91L","user":"U2147483697","text":%q,"ts":"1355517523.000005"}}`, id, text)
+}
+
+func TestSlackEventsHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		body      string
+		secret    string
+		headers   map[string]string
+		seen      []string
+		queue     int
+		wantCode  int
+		wantBody  string
+		wantEvent bool
+	}{
+		{
+			name:     "URL verification",
+			body:     `{"type":"url_verification","challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`,
+			queue:    1,
+			wantCode: http.StatusOK,
+			wantBody: "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P",
+		},
+		{
+			name:      "message event",
+			body:      messageCallback("Ev0PV52K21", "ALERT: disk full"),
+			queue:     1,
+			wantCode:  http.StatusOK,
+			wantEvent: true,
+		},
+		{
+			name:     "redelivered event",
+			body:     messageCallback("Ev0PV52K21", "ALERT: disk full"),
+			headers:  map[string]string{"X-Slack-Retry-Num": "1", "X-Slack-Retry-Reason": "http_timeout"},
+			seen:     []string{"Ev0PV52K21"},
+			queue:    1,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:      "retried event not received before",
+			body:      messageCallback("Ev0PV52K21", "ALERT: disk full"),
+			headers:   map[string]string{"X-Slack-Retry-Num": "1", "X-Slack-Retry-Reason": "http_error"},
+			seen:      []string{"Ev0PV52K22"},
+			queue:     1,
+			wantCode:  http.StatusOK,
+			wantEvent: true,
+		},
+		{
+			name:     "full queue",
+			body:     messageCallback("Ev0PV52K21", "ALERT: disk full"),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "rate limited callback",
+			body:     `{"type":"app_rate_limited","minute_rate_limited":1518467820}`,
+			queue:    1,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "invalid signature",
+			body:     messageCallback("Ev0PV52K21", "ALERT: disk full"),
+			secret:   "invalid",
+			queue:    1,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "invalid JSON",
+			body:     `{"type":`,
+			queue:    1,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid method",
+			method:   http.MethodGet,
+			queue:    1,
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewSlackEventsMonitor(&SlackEventsConfig{
+				SigningSecret: testSigningSecret,
+				Msg:           "^ALERT",
+			})
+			if err != nil {
+				t.Fatalf("failed to create monitor: %v", err)
+			}
+
+			for _, id := range tt.seen {
+				m.seen(id)
+			}
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			secret := tt.secret
+			if secret == "" {
+				secret = testSigningSecret
+			}
+
+			req := httptest.NewRequest(method, DefaultSlackEventsPath, strings.NewReader(tt.body))
+			signSlackRequest(req, secret, []byte(tt.body))
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			eventChan := make(chan *msgEvent, tt.queue)
+			w := httptest.NewRecorder()
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				m.handleEvents(eventChan)(w, req)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("handler blocked")
+			}
+
+			if w.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if got := len(eventChan) > 0; got != tt.wantEvent {
+				t.Errorf("event dispatched = %v, want %v", got, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestSlackEventsMonitorSeen(t *testing.T) {
+	m, err := NewSlackEventsMonitor(&SlackEventsConfig{SigningSecret: testSigningSecret})
+	if err != nil {
+		t.Fatalf("failed to create monitor: %v", err)
+	}
+
+	for i := 0; i <= maxSlackEventIDs; i++ {
+		if m.seen(fmt.Sprintf("Ev%d", i)) {
+			t.Fatalf("event Ev%d seen before it was received", i)
+		}
+	}
+
+	if m.seen("") {
+		t.Errorf("event without ID seen")
+	}
+	if !m.seen(fmt.Sprintf("Ev%d", maxSlackEventIDs)) {
+		t.Errorf("recent event not seen")
+	}
+	if m.seen("Ev0") {
+		t.Errorf("oldest event still seen")
+	}
+	if len(m.eventIDs) != maxSlackEventIDs || len(m.eventOrder) != maxSlackEventIDs {
+		t.Errorf("kept %d event IDs, want %d", len(m.eventIDs), maxSlackEventIDs)
+	}
+}
+
+// fakeSocketMode is fake Slack Web API which serves Socket Mode connection sending envelopes
+type fakeSocketMode struct {
+	// srv serves Web API and Socket Mode connection
+	srv *httptest.Server
+	// envelopes are sent over Socket Mode connection
+	envelopes []string
+	// acks receives acknowledged envelope IDs
+	acks chan string
+}
+
+// newFakeSocketMode starts fake Slack Socket Mode and returns it
+func newFakeSocketMode(t *testing.T, appToken string, envelopes ...string) *fakeSocketMode {
+	f := &fakeSocketMode{
+		envelopes: envelopes,
+		acks:      make(chan string, len(envelopes)),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/apps.connections.open", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+appToken {
+			fmt.Fprint(w, `{"ok":false,"error":"invalid_auth"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"ok":true,"url":"ws%s/link"}`, strings.TrimPrefix(f.srv.URL, "http"))
+	})
+	mux.HandleFunc("/link", f.serve)
+
+	f.srv = httptest.NewServer(mux)
+	t.Cleanup(f.srv.Close)
+
+	return f
+}
+
+// serve sends envelopes over Socket Mode connection and records their acknowledgements
+func (f *fakeSocketMode) serve(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"hello","num_connections":1}`)); err != nil {
+		return
+	}
+
+	for _, env := range f.envelopes {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(env)); err != nil {
+			return
+		}
+
+		var ack struct {
+			EnvelopeID string `json:"envelope_id"`
+		}
+		if err := conn.ReadJSON(&ack); err != nil {
+			return
+		}
+		f.acks <- ack.EnvelopeID
+	}
+
+	// keep the connection open until the client closes it
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// eventsEnvelope returns Socket Mode envelope carrying Events API callback
+func eventsEnvelope(envelopeID, callback string) string {
+	env, _ := json.Marshal(map[string]interface{}{
+		"envelope_id": envelopeID,
+		"type":        "events_api",
+		"payload":     json.RawMessage(callback),
+	})
+
+	return string(env)
+}
+
+func TestSlackEventsMonitorSocketMode(t *testing.T) {
+	f := newFakeSocketMode(t, "xapp-1-A0123",
+		eventsEnvelope("57d6a792-4d35-4d0b-b6aa-3361493e1caf", messageCallback("Ev0PV52K21", "ALERT: disk full")),
+		// Slack redelivers events which it considers unacknowledged
+		eventsEnvelope("3f1a6d82-9f04-4b88-8a2c-b0b4dd0fc85b", messageCallback("Ev0PV52K21", "ALERT: disk full")),
+		eventsEnvelope("c2a7b3b6-3f79-4b0c-8d28-4b1d2f3bbd1a", messageCallback("Ev0PV52K22", "RESOLVED: disk full")),
+	)
+
+	m, err := NewSlackEventsMonitor(&SlackEventsConfig{
+		AppToken: "xapp-1-A0123",
+		APIURL:   f.srv.URL + "/api",
+		Channel:  "C024BE91L",
+		User:     "U2147483697",
+		Msg:      "^ALERT",
+		Resolve:  "^RESOLVED",
+	})
+	if err != nil {
+		t.Fatalf("failed to create monitor: %v", err)
+	}
+
+	msgChan := make(chan *alertify.Msg)
+	errChan := runMonitor(m, msgChan)
+
+	for _, want := range []string{ActionAlert, ActionSilence} {
+		msg := receiveCommand(t, msgChan)
+		if msg.Cmd != want {
+			t.Fatalf("command = %s, want %s", msg.Cmd, want)
+		}
+		msg.Resp <- nil
+	}
+
+	for _, want := range []string{
+		"57d6a792-4d35-4d0b-b6aa-3361493e1caf",
+		"3f1a6d82-9f04-4b88-8a2c-b0b4dd0fc85b",
+		"c2a7b3b6-3f79-4b0c-8d28-4b1d2f3bbd1a",
+	} {
+		select {
+		case got := <-f.acks:
+			if got != want {
+				t.Errorf("acknowledged envelope %s, want %s", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("envelope %s not acknowledged", want)
+		}
+	}
+
+	expectNoCommand(t, msgChan, 100*time.Millisecond)
+
+	if err := stopMonitor(t, m, errChan); err != nil {
+		t.Errorf("MonitorAndAlert() error = %v", err)
+	}
+}
+
+func TestSlackEventsMonitorSocketModeInvalidToken(t *testing.T) {
+	f := newFakeSocketMode(t, "xapp-1-A0123")
+
+	m, err := NewSlackEventsMonitor(&SlackEventsConfig{
+		AppToken: "xapp-1-invalid",
+		APIURL:   f.srv.URL + "/api/",
+		Msg:      "^ALERT",
+	})
+	if err != nil {
+		t.Fatalf("failed to create monitor: %v", err)
+	}
+
+	select {
+	case err := <-runMonitor(m, make(chan *alertify.Msg)):
+		if !alertify.IsFatal(err) {
+			t.Errorf("MonitorAndAlert() error = %v, want fatal error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("monitor did not fail")
+	}
+}
@@ -0,0 +1,133 @@
+package monitor
+
+import (
+	"github.com/milosgajdos/alertify"
+	"github.com/nlopes/slack"
+)
+
+// slackEvent is Slack Events API message event
+type slackEvent struct {
+	Type     string `json:"type"`
+	Subtype  string `json:"subtype"`
+	Channel  string `json:"channel"`
+	User     string `json:"user"`
+	BotID    string `json:"bot_id"`
+	Username string `json:"username"`
+	Text     string `json:"text"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts"`
+}
+
+// msgEvent converts Slack Events API message event to msgEvent
+func (ev *slackEvent) msgEvent() *msgEvent {
+	return &msgEvent{
+		Type:     ev.Type,
+		Subtype:  ev.Subtype,
+		Channel:  ev.Channel,
+		User:     ev.User,
+		BotID:    ev.BotID,
+		Username: ev.Username,
+		Text:     ev.Text,
+		TS:       ev.TS,
+		ThreadTS: ev.ThreadTS,
+	}
+}
+
+// newSlackEvent converts Slack RTM message event to msgEvent
+func newSlackEvent(ev *slack.MessageEvent) *msgEvent {
+	return &msgEvent{
+		Type:     "message",
+		Subtype:  ev.SubType,
+		Channel:  ev.Channel,
+		User:     ev.User,
+		BotID:    ev.BotID,
+		Username: ev.Username,
+		Text:     ev.Text,
+		TS:       ev.Timestamp,
+		ThreadTS: ev.ThreadTimestamp,
+	}
+}
+
+// apiNames looks up Slack names via Slack Web API
+type apiNames struct {
+	// api is Slack Web API client
+	api *slackAPI
+	// token is Slack bot token
+	token string
+}
+
+// userName returns the name of user with the given ID
+func (n *apiNames) userName(id string) (string, error) {
+	return n.api.userName(n.token, id)
+}
+
+// botName returns the name of bot with the given ID
+func (n *apiNames) botName(id string) (string, error) {
+	return n.api.botName(n.token, id)
+}
+
+// channelName returns the name of channel with the given ID
+func (n *apiNames) channelName(id string) (string, error) {
+	return n.api.channelName(n.token, id)
+}
+
+// identity returns IDs of user and bot the token belongs to
+func (n *apiNames) identity() (string, string, error) {
+	return n.api.authTest(n.token)
+}
+
+// clientNames looks up Slack names via Slack API client
+type clientNames struct {
+	*slack.Client
+}
+
+// userName returns the name of user with the given ID
+func (n *clientNames) userName(id string) (string, error) {
+	user, err := n.GetUserInfo(id)
+	if err != nil {
+		return "", slackErr(err)
+	}
+
+	return user.Name, nil
+}
+
+// botName returns the name of bot with the given ID
+func (n *clientNames) botName(id string) (string, error) {
+	bot, err := n.GetBotInfo(id)
+	if err != nil {
+		return "", slackErr(err)
+	}
+
+	return bot.Name, nil
+}
+
+// channelName returns the name of channel with the given ID
+func (n *clientNames) channelName(id string) (string, error) {
+	channel, err := n.GetConversationInfo(id, false)
+	if err != nil {
+		return "", slackErr(err)
+	}
+
+	return channel.Name, nil
+}
+
+// identity returns ID of user the client token belongs to
+// Slack API client doesn't return bot ID, but bot messages carry the bot user ID, too.
+func (n *clientNames) identity() (string, string, error) {
+	auth, err := n.AuthTest()
+	if err != nil {
+		return "", "", slackErr(err)
+	}
+
+	return auth.UserID, "", nil
+}
+
+// slackErr marks Slack API client errors caused by bad credentials as fatal
+func slackErr(err error) error {
+	switch err.Error() {
+	case "invalid_auth", "not_authed", "account_inactive", "token_revoked":
+		return alertify.Fatal(err)
+	}
+
+	return err
+}
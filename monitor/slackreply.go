@@ -0,0 +1,145 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/milosgajdos/alertify"
+)
+
+const (
+	// SilenceActionID is the action ID of Slack silence button
+	SilenceActionID = "alertify_silence"
+	// ReplyThread replies to the matched messages in their threads
+	ReplyThread = "thread"
+	// ReplyReaction reacts to the matched messages with emoji
+	ReplyReaction = "reaction"
+)
+
+// silenceBlocks returns Slack message blocks with text and silence button
+func silenceBlocks(text string) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": text},
+		},
+		map[string]interface{}{
+			"type": "actions",
+			"elements": []interface{}{
+				map[string]interface{}{
+					"type":      "button",
+					"action_id": SilenceActionID,
+					"style":     "danger",
+					"text":      map[string]string{"type": "plain_text", "text": "Acknowledge / Silence"},
+				},
+			},
+		},
+	}
+}
+
+// slackReplier replies to matched Slack messages
+type slackReplier struct {
+	// api is Slack Web API client
+	api *slackAPI
+	// token is Slack bot token
+	token string
+	// mode is either ReplyThread, ReplyReaction or empty for no replies
+	mode string
+	// silenceButton posts silence button in reply to alert messages
+	silenceButton bool
+}
+
+// newSlackReplier creates new Slack replier and returns it
+// It returns error if the reply mode is not supported.
+func newSlackReplier(api *slackAPI, token, mode string, silenceButton bool) (*slackReplier, error) {
+	if mode != "" && mode != ReplyThread && mode != ReplyReaction {
+		return nil, fmt.Errorf("unsupported Slack reply mode: %s", mode)
+	}
+
+	if (mode != "" || silenceButton) && token == "" {
+		return nil, fmt.Errorf("missing Slack API key to reply with")
+	}
+
+	return &slackReplier{
+		api:           api,
+		token:         token,
+		mode:          mode,
+		silenceButton: silenceButton,
+	}, nil
+}
+
+// outcome returns the outcome of the matched message action described by bot response resp
+// It returns false if the action failed.
+func outcome(action string, resp interface{}) (string, bool) {
+	err := alertify.RespError(resp)
+
+	switch {
+	case action == ActionSilence && err != nil:
+		return fmt.Sprintf("Could not silence alert: %v", err), false
+	case action == ActionSilence:
+		return "Alert silenced", true
+	case err != nil:
+		return fmt.Sprintf("Could not play alert: %v", err), false
+	}
+
+	if result, ok := resp.(*alertify.AlertResult); ok && len(result.Devices) > 0 {
+		return fmt.Sprintf("Alert %s", result), true
+	}
+
+	return "Alert played", true
+}
+
+// reaction returns the emoji which reacts to the matched message action outcome
+func reaction(action string, ok bool) string {
+	switch {
+	case !ok:
+		return "x"
+	case action == ActionSilence:
+		return "mute"
+	}
+
+	return "white_check_mark"
+}
+
+// reply replies to matched message with the outcome described by bot response resp
+// Silence button is posted in the message thread if the alert has been played.
+func (r *slackReplier) reply(match *msgMatch, resp interface{}) {
+	action := match.rule.action
+	text, ok := outcome(action, resp)
+	button := r.silenceButton && action == ActionAlert && ok
+
+	if r.mode == ReplyReaction {
+		params := url.Values{
+			"channel":   {match.event.Channel},
+			"timestamp": {match.event.TS},
+			"name":      {reaction(action, ok)},
+		}
+		if err := r.api.call("reactions.add", r.token, params, nil); err != nil {
+			log.Printf("Could not react to Slack message: %v", err)
+		}
+	}
+
+	if r.mode != ReplyThread && !button {
+		return
+	}
+
+	// messages posted in threads are replied to in the same thread
+	thread := match.event.ThreadTS
+	if thread == "" {
+		thread = match.event.TS
+	}
+
+	msg := &slackMessage{
+		Channel:  match.event.Channel,
+		ThreadTS: thread,
+		Text:     text,
+	}
+	if button {
+		msg.Blocks = silenceBlocks(text)
+	}
+
+	if err := r.api.postMessage(r.token, msg); err != nil {
+		log.Printf("Could not reply to Slack message: %v", err)
+	}
+}
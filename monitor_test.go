@@ -0,0 +1,129 @@
+package alertify
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeMonitor is a monitor which runs until it's stopped
+type fakeMonitor struct {
+	// doneChan stops the monitor
+	doneChan chan struct{}
+}
+
+// MonitorAndAlert waits until the monitor is stopped
+func (m *fakeMonitor) MonitorAndAlert(msgChan chan<- *Msg) error {
+	<-m.doneChan
+	return nil
+}
+
+// Stop stops the monitor
+func (m *fakeMonitor) Stop() {
+	select {
+	case <-m.doneChan:
+	default:
+		close(m.doneChan)
+	}
+}
+
+// String returns the name of the monitor
+func (m *fakeMonitor) String() string {
+	return "Fake Monitor"
+}
+
+func TestMonitorEntryForward(t *testing.T) {
+	tests := []struct {
+		name    string
+		paused  bool
+		resp    chan interface{}
+		wantErr bool
+	}{
+		{
+			name: "forwarded",
+			resp: make(chan interface{}, 1),
+		},
+		{
+			name:    "paused",
+			paused:  true,
+			resp:    make(chan interface{}, 1),
+			wantErr: true,
+		},
+		{
+			name:   "paused without response channel",
+			paused: true,
+		},
+		{
+			name:   "paused with unbuffered response channel",
+			paused: true,
+			resp:   make(chan interface{}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newMonitorEntry("fake", &fakeMonitor{doneChan: make(chan struct{})})
+			e.isRunning = true
+			e.setPaused(tt.paused)
+
+			msgChan := make(chan *Msg, 1)
+			forwardDone := make(chan struct{})
+			go func() {
+				defer close(forwardDone)
+				e.forward(msgChan)
+			}()
+
+			for i := 0; i < 2; i++ {
+				select {
+				case e.msgChan <- &Msg{Cmd: "alert", Resp: tt.resp}:
+				case <-time.After(time.Second):
+					t.Fatal("forwarder blocked")
+				}
+
+				if tt.paused {
+					if tt.wantErr {
+						if err := RespError(<-tt.resp); err == nil {
+							t.Errorf("paused monitor message got no error")
+						}
+					}
+					continue
+				}
+
+				if msg := <-msgChan; msg.Cmd != "alert" {
+					t.Errorf("forwarded %s, want alert", msg.Cmd)
+				}
+			}
+
+			e.stop()
+			select {
+			case <-forwardDone:
+			case <-time.After(time.Second):
+				t.Fatal("forwarder not stopped")
+			}
+		})
+	}
+}
+
+func TestSendMsg(t *testing.T) {
+	c := &Context{msgChan: make(chan *Msg), closeChan: make(chan struct{})}
+
+	go func() {
+		msg := <-c.msgChan
+		msg.Resp <- fmt.Errorf("%s failed", msg.Cmd)
+	}()
+
+	resp, ok := sendMsg(c, "silence", nil)
+	if !ok || RespError(resp) == nil {
+		t.Fatalf("sendMsg() = %v, %v, want error response", resp, ok)
+	}
+
+	// stopped bot doesn't receive the message, so sendMsg must not wait for Timeout
+	close(c.closeChan)
+	start := time.Now()
+	if _, ok := sendMsg(c, "silence", nil); ok {
+		t.Errorf("stopped bot responded")
+	}
+	if d := time.Since(start); d >= Timeout {
+		t.Errorf("sendMsg() waited %s for stopped bot", d)
+	}
+}
@@ -0,0 +1,260 @@
+package alertify
+
+import (
+	"io"
+	"log"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+const (
+	// RestorePollInterval is how often bot checks whether the alert is still playing
+	RestorePollInterval = 5 * time.Second
+)
+
+// Playback is a snapshot of Spotify playback state
+type Playback struct {
+	// DeviceID is ID of the device the playback was active on
+	DeviceID spotify.ID
+	// ContextURI is the URI of the playback context (album, playlist, artist)
+	ContextURI spotify.URI
+	// TrackURI is the URI of the playing track
+	TrackURI spotify.URI
+	// Position is the track playback position in milliseconds
+	Position int
+	// Volume is the device volume in percent
+	Volume int
+	// Shuffle is the shuffle state
+	Shuffle bool
+	// Repeat is the repeat state: off, track or context
+	Repeat string
+	// Playing is true if the track was playing
+	Playing bool
+}
+
+// Playback returns a snapshot of the current Spotify playback
+// It returns nil Playback if there is no active playback.
+func (s *SpotifyClient) Playback() (*Playback, error) {
+	s.Lock()
+	defer s.Unlock()
+	defer s.persistToken()
+
+	state, err := s.PlayerState()
+	if err != nil {
+		// Spotify API returns empty response when there is no active device
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if state.Item == nil || state.Device.ID == "" {
+		return nil, nil
+	}
+
+	return &Playback{
+		DeviceID:   state.Device.ID,
+		ContextURI: state.PlaybackContext.URI,
+		TrackURI:   state.Item.URI,
+		Position:   state.Progress,
+		Volume:     state.Device.Volume,
+		Shuffle:    state.ShuffleState,
+		Repeat:     state.RepeatState,
+		Playing:    state.Playing,
+	}, nil
+}
+
+// Restore restores Spotify playback from snapshot p
+// If p is nil the playback on the alert device is paused.
+// Spotify API can't load playback without starting it, so the playback which was paused
+// is restored muted and paused straight away before its volume is restored.
+func (s *SpotifyClient) Restore(p *Playback) error {
+	if p == nil {
+		return s.Pause()
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	defer s.persistToken()
+
+	log.Printf("Restoring playback of %s on Device ID: %s", p.TrackURI, p.DeviceID)
+
+	deviceID := p.DeviceID
+	opts := &spotify.PlayOptions{DeviceID: &deviceID}
+
+	return s.retry(func() error {
+		playOpts := &spotify.PlayOptions{DeviceID: &deviceID}
+		if p.ContextURI != "" {
+			contextURI := p.ContextURI
+			playOpts.PlaybackContext = &contextURI
+			playOpts.PlaybackOffset = &spotify.PlaybackOffset{URI: p.TrackURI}
+		} else {
+			playOpts.URIs = []spotify.URI{p.TrackURI}
+		}
+
+		if !p.Playing {
+			if err := s.VolumeOpt(0, opts); err != nil {
+				return err
+			}
+		}
+		if err := s.PlayOpt(playOpts); err != nil {
+			return err
+		}
+		if !p.Playing {
+			if err := s.PauseOpt(opts); err != nil {
+				return err
+			}
+		}
+		if err := s.SeekOpt(p.Position, opts); err != nil {
+			return err
+		}
+		if err := s.ShuffleOpt(p.Shuffle, opts); err != nil {
+			return err
+		}
+		if p.Repeat != "" {
+			if err := s.RepeatOpt(p.Repeat, opts); err != nil {
+				return err
+			}
+		}
+
+		return s.VolumeOpt(p.Volume, opts)
+	})
+}
+
+// IsPlaying checks if uri is being played on the alert device
+// uri can be either a track URI or a playback context URI.
+func (s *SpotifyClient) IsPlaying(uri string) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	defer s.persistToken()
+
+	state, err := s.PlayerState()
+	if err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if !state.Playing || state.Device.ID != s.device.ID {
+		return false, nil
+	}
+
+	if string(state.PlaybackContext.URI) == uri {
+		return true, nil
+	}
+
+	return state.Item != nil && string(state.Item.URI) == uri, nil
+}
+
+// savePlayback takes a snapshot of the current playback unless a snapshot has already been taken
+func (b *Bot) savePlayback() {
+	b.Lock()
+	saved := b.playback != nil
+	b.Unlock()
+
+	// alert is already playing over the saved playback
+	if saved {
+		return
+	}
+
+	p, err := b.client().Playback()
+	if err != nil {
+		log.Printf("Failed to save Spotify playback: %s", err)
+		return
+	}
+	if p == nil {
+		return
+	}
+
+	b.Lock()
+	b.playback = p
+	b.Unlock()
+}
+
+// restorePlayback restores saved playback
+// It returns false if there was no saved playback.
+func (b *Bot) restorePlayback() (bool, error) {
+	b.Lock()
+	p := b.playback
+	b.playback = nil
+	b.Unlock()
+
+	if p == nil {
+		return false, nil
+	}
+
+	return true, b.client().Restore(p)
+}
+
+// watchPlayback restores saved playback and volume once the alert song uri stops playing
+// They are restored by the message listener, so that the watcher of an old alert doesn't
+// restore them over a new alert. It stops watching when doneChan is closed.
+func (b *Bot) watchPlayback(uri string, doneChan chan struct{}) {
+	ticker := time.NewTicker(RestorePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			playing, err := b.client().IsPlaying(uri)
+			if err != nil {
+				log.Printf("Failed to read Spotify playback state: %s", err)
+				continue
+			}
+			if playing {
+				continue
+			}
+			log.Printf("Alert finished playing")
+			b.sendAlertMsg("alert.finished", doneChan, doneChan)
+			return
+		case <-doneChan:
+			return
+		}
+	}
+}
+
+// finishAlert restores saved playback and volume once the alert whose background tasks
+// are stopped by doneChan has finished playing. It does nothing if another alert has
+// started playing or if the alert has been silenced in the meantime.
+func (b *Bot) finishAlert(doneChan chan struct{}) {
+	b.Lock()
+	current := doneChan != nil && b.alertDone == doneChan
+	b.Unlock()
+
+	if !current {
+		return
+	}
+
+	b.stopAlertTasks()
+
+	if err := b.restoreVolume(); err != nil {
+		log.Printf("Failed to restore Spotify device volume: %s", err)
+	}
+	if _, err := b.restorePlayback(); err != nil {
+		log.Printf("Failed to restore Spotify playback: %s", err)
+	}
+}
+
+// startAlertTasks creates a new done channel for the background tasks of the playing alert
+func (b *Bot) startAlertTasks() chan struct{} {
+	doneChan := make(chan struct{})
+
+	b.Lock()
+	b.alertDone = doneChan
+	b.Unlock()
+
+	return doneChan
+}
+
+// stopAlertTasks stops background tasks of the playing alert
+func (b *Bot) stopAlertTasks() {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.alertDone != nil {
+		close(b.alertDone)
+		b.alertDone = nil
+	}
+}
@@ -0,0 +1,62 @@
+package alertify
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestBotFinishAlert(t *testing.T) {
+	volume := 30
+
+	tests := []struct {
+		name        string
+		stale       bool
+		wantStopped bool
+		wantVolume  *int
+		wantPlay    *Playback
+	}{
+		{
+			name:        "finished alert",
+			wantStopped: true,
+		},
+		{
+			// watcher of an old alert must not restore the playback interrupted by the new alert
+			name:       "stale watcher",
+			stale:      true,
+			wantVolume: &volume,
+			wantPlay:   &Playback{DeviceID: "device", TrackURI: "spotify:track:4uLU6hMCjMI75M1A2tKUQC"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Bot{Mutex: &sync.Mutex{}}
+			if tt.stale {
+				b.volume = tt.wantVolume
+				b.playback = tt.wantPlay
+			}
+
+			doneChan := b.startAlertTasks()
+			if tt.stale {
+				b.stopAlertTasks()
+				b.startAlertTasks()
+			}
+
+			b.finishAlert(doneChan)
+
+			if tt.wantStopped && b.alertDone != nil {
+				t.Errorf("alert tasks not stopped")
+			}
+
+			if tt.stale && b.alertDone == nil {
+				t.Errorf("tasks of the new alert stopped")
+			}
+			if b.volume != tt.wantVolume {
+				t.Errorf("saved volume = %v, want %v", b.volume, tt.wantVolume)
+			}
+			if b.playback != tt.wantPlay {
+				t.Errorf("saved playback = %v, want %v", b.playback, tt.wantPlay)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package alertify
+
+import (
+	"fmt"
+	"log"
+)
+
+const (
+	// SpotifyPlayer is the name of the built-in Spotify player
+	SpotifyPlayer = "spotify"
+)
+
+// Player plays alert songs on some audio output
+type Player interface {
+	// Play starts playing song
+	Play(*Song) error
+	// Stop stops the playback
+	Stop() error
+	// String implements stringer interface
+	String() string
+}
+
+// player returns player registered under name
+func (b *Bot) player(name string) (Player, error) {
+	p, ok := b.players[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown player: %s", name)
+	}
+
+	return p, nil
+}
+
+// alertPlayer plays song with the player registered under name
+func (b *Bot) alertPlayer(song *Song, name string) (*AlertResult, error) {
+	result := &AlertResult{Song: song.URI}
+
+	p, err := b.player(name)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Attempting to play %s with %s", song.URI, p)
+
+	err = p.Play(song)
+	res := &DeviceResult{Device: p.String()}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	result.Devices = append(result.Devices, res)
+
+	if err != nil {
+		return result, err
+	}
+
+	b.Lock()
+	b.playing = name
+	b.Unlock()
+
+	doneChan := b.startAlertTasks()
+	if song.MaxDuration > 0 {
+		go b.limitDuration(song.MaxDuration, doneChan)
+	}
+
+	return result, nil
+}
+
+// fallback plays song with the song fallback player after the alert failed with err
+// Spotify search expressions only make sense to Spotify, so the fallback player plays
+// its configured media instead of them.
+// It returns the original result and error if there is no fallback player.
+func (b *Bot) fallback(song *Song, result *AlertResult, err error) (*AlertResult, error) {
+	if song.FallbackPlayer == "" {
+		return result, err
+	}
+
+	log.Printf("Failed to play alert: %s, falling back to %s player", err, song.FallbackPlayer)
+
+	fbSong := *song
+	if (song.Player == "" || song.Player == SpotifyPlayer) && IsSearchQuery(song.URI) {
+		fbSong.URI = ""
+	}
+
+	fbResult, fbErr := b.alertPlayer(&fbSong, song.FallbackPlayer)
+	if fbResult == nil {
+		return result, fmt.Errorf("%s; fallback failed: %s", err, fbErr)
+	}
+
+	if result != nil {
+		fbResult.Devices = append(result.Devices, fbResult.Devices...)
+	}
+
+	return fbResult, fbErr
+}
+
+// stopPlayer stops the player which plays the current alert
+// It returns false if the alert is not played by any player.
+func (b *Bot) stopPlayer() (bool, error) {
+	b.Lock()
+	name := b.playing
+	b.playing = ""
+	b.Unlock()
+
+	if name == "" {
+		return false, nil
+	}
+
+	p, err := b.player(name)
+	if err != nil {
+		return true, err
+	}
+
+	log.Printf("Stopping alert playback on %s", p)
+
+	return true, p.Stop()
+}
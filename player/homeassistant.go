@@ -0,0 +1,248 @@
+package player
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/milosgajdos/alertify"
+)
+
+const (
+	// DefaultHomeAssistantURL is the default Home Assistant URL
+	DefaultHomeAssistantURL = "http://localhost:8123"
+	// DefaultHomeAssistantTimeout is the default Home Assistant request timeout
+	DefaultHomeAssistantTimeout = 10 * time.Second
+	// DefaultMediaType is the default media content type
+	DefaultMediaType = "music"
+)
+
+// HomeAssistantConfig configures Home Assistant media player
+type HomeAssistantConfig struct {
+	// URL is Home Assistant URL. It defaults to DefaultHomeAssistantURL.
+	URL string
+	// Token is Home Assistant long-lived access token
+	Token string
+	// Entity is media player entity ID, e.g. media_player.office
+	Entity string
+	// Media is the media content ID played when the song is a Spotify song or has no URI.
+	// Other song URIs, e.g. media-source:// IDs or stream URLs, are played as they are.
+	Media string
+	// MediaType is the media content type. It defaults to DefaultMediaType.
+	MediaType string
+	// SpotifyMedia plays Spotify songs as they are, e.g. on Spotify or Sonos entities
+	SpotifyMedia bool
+	// Timeout is the request timeout. It defaults to DefaultHomeAssistantTimeout.
+	Timeout time.Duration
+}
+
+// HomeAssistantPlayer plays alerts on Home Assistant media player entity
+type HomeAssistantPlayer struct {
+	// url is Home Assistant URL
+	url string
+	// token is Home Assistant access token
+	token string
+	// entity is media player entity ID
+	entity string
+	// media is the default media content ID
+	media string
+	// mediaType is the media content type
+	mediaType string
+	// spotifyMedia plays Spotify songs as they are
+	spotifyMedia bool
+	// client sends Home Assistant API requests
+	client *http.Client
+}
+
+// NewHomeAssistantPlayer creates new Home Assistant media player and returns it
+// It returns error if the token or entity is not configured.
+func NewHomeAssistantPlayer(c *HomeAssistantConfig) (*HomeAssistantPlayer, error) {
+	if c.Token == "" {
+		return nil, fmt.Errorf("missing Home Assistant token")
+	}
+
+	if !strings.HasPrefix(c.Entity, "media_player.") {
+		return nil, fmt.Errorf("invalid media player entity: %q", c.Entity)
+	}
+
+	haURL := c.URL
+	if haURL == "" {
+		haURL = DefaultHomeAssistantURL
+	}
+
+	if _, err := url.Parse(haURL); err != nil {
+		return nil, fmt.Errorf("invalid Home Assistant URL %s: %s", haURL, err)
+	}
+
+	mediaType := c.MediaType
+	if mediaType == "" {
+		mediaType = DefaultMediaType
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHomeAssistantTimeout
+	}
+
+	return &HomeAssistantPlayer{
+		url:          strings.TrimSuffix(haURL, "/"),
+		token:        c.Token,
+		entity:       c.Entity,
+		media:        c.Media,
+		mediaType:    mediaType,
+		spotifyMedia: c.SpotifyMedia,
+		client:       &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// String returns the name of the player
+func (p *HomeAssistantPlayer) String() string {
+	return fmt.Sprintf("Home Assistant Player (%s)", p.entity)
+}
+
+// mediaID returns media content ID song should be played from
+func (p *HomeAssistantPlayer) mediaID(song *alertify.Song) (string, error) {
+	media := p.media
+	if song != nil && song.URI != "" && (p.spotifyMedia || !alertify.IsSpotifyURI(song.URI)) {
+		media = song.URI
+	}
+
+	if media == "" {
+		return "", fmt.Errorf("no media to play")
+	}
+
+	return media, nil
+}
+
+// Play sets the entity volume and plays song media on it
+// If the song is a Spotify song, the configured media is played unless Spotify media is enabled.
+func (p *HomeAssistantPlayer) Play(song *alertify.Song) error {
+	media, err := p.mediaID(song)
+	if err != nil {
+		return err
+	}
+
+	if song != nil && song.Volume != 0 {
+		if err := p.setVolume(song); err != nil {
+			log.Printf("Failed to set volume on %s: %s", p.entity, err)
+		}
+	}
+
+	if err := p.call("play_media", map[string]interface{}{
+		"entity_id":          p.entity,
+		"media_content_id":   media,
+		"media_content_type": p.mediaType,
+	}); err != nil {
+		return err
+	}
+
+	log.Printf("Playing %s on %s", media, p.entity)
+
+	return nil
+}
+
+// setVolume sets the entity volume to song volume
+// Relative song volume is added to the current entity volume.
+func (p *HomeAssistantPlayer) setVolume(song *alertify.Song) error {
+	volume := song.Volume
+	if song.RelativeVolume {
+		current, err := p.volume()
+		if err != nil {
+			return err
+		}
+		volume += current
+	}
+
+	return p.call("volume_set", map[string]interface{}{
+		"entity_id":    p.entity,
+		"volume_level": float64(alertify.ClampVolume(volume)) / 100,
+	})
+}
+
+// volume returns the current entity volume in percent
+func (p *HomeAssistantPlayer) volume() (int, error) {
+	var state struct {
+		Attributes struct {
+			VolumeLevel *float64 `json:"volume_level"`
+		} `json:"attributes"`
+	}
+
+	if err := p.get("/api/states/"+p.entity, &state); err != nil {
+		return 0, err
+	}
+
+	// entities which are off or don't support volume have no volume_level
+	if state.Attributes.VolumeLevel == nil {
+		return 0, fmt.Errorf("%s volume not available", p.entity)
+	}
+
+	return int(math.Round(*state.Attributes.VolumeLevel * 100)), nil
+}
+
+// Stop stops the playback on the entity
+func (p *HomeAssistantPlayer) Stop() error {
+	return p.call("media_stop", map[string]interface{}{
+		"entity_id": p.entity,
+	})
+}
+
+// get sends GET request to Home Assistant API path and decodes the response into v
+func (p *HomeAssistantPlayer) get(path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, p.url+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("GET %s failed: %s", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("GET %s failed: %s: %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response of GET %s: %s", path, err)
+	}
+
+	return nil
+}
+
+// call calls Home Assistant media_player service with data
+func (p *HomeAssistantPlayer) call(service string, data map[string]interface{}) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.url+"/api/services/media_player/"+service, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("media_player.%s failed: %s", service, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("media_player.%s failed: %s: %s", service, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
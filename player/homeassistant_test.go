@@ -0,0 +1,272 @@
+package player
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/milosgajdos/alertify"
+)
+
+const testHAToken = "secret-token"
+
+// fakeHomeAssistant is a fake Home Assistant API which records called services
+type fakeHomeAssistant struct {
+	// srv serves Home Assistant API
+	srv *httptest.Server
+	// state is the entity state returned by the states API
+	state string
+	// fail is the service which returns error
+	fail string
+	// calls are the called services
+	calls []string
+	// mutex
+	*sync.Mutex
+}
+
+// newFakeHomeAssistant starts fake Home Assistant API and returns it
+func newFakeHomeAssistant(t *testing.T, state, fail string) *fakeHomeAssistant {
+	h := &fakeHomeAssistant{
+		state: state,
+		fail:  fail,
+		Mutex: &sync.Mutex{},
+	}
+
+	h.srv = httptest.NewServer(http.HandlerFunc(h.serve))
+	t.Cleanup(h.srv.Close)
+
+	return h
+}
+
+// called returns the called services
+func (h *fakeHomeAssistant) called() []string {
+	h.Lock()
+	defer h.Unlock()
+
+	return append([]string(nil), h.calls...)
+}
+
+// serve serves Home Assistant states and services API
+func (h *fakeHomeAssistant) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+testHAToken {
+		http.Error(w, "401: Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/states/media_player.office":
+		if h.state == "" {
+			http.Error(w, `{"message": "Entity not found."}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, h.state)
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/api/services/media_player/"):
+		service := strings.TrimPrefix(r.URL.Path, "/api/services/media_player/")
+
+		var data map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		h.Lock()
+		h.calls = append(h.calls, fmt.Sprintf("%s %v", service, data))
+		h.Unlock()
+
+		if service == h.fail {
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, "[]")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestHomeAssistantPlayerPlay(t *testing.T) {
+	tests := []struct {
+		name    string
+		media   string
+		spotify bool
+		state   string
+		fail    string
+		song    *alertify.Song
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "default media",
+			media: "media-source://media_source/local/alert.mp3",
+			want: []string{
+				"play_media map[entity_id:media_player.office media_content_id:media-source://media_source/local/alert.mp3 media_content_type:music]",
+			},
+		},
+		{
+			name:  "Spotify song plays default media",
+			media: "media-source://media_source/local/alert.mp3",
+			song:  &alertify.Song{URI: "spotify:track:7yTIKQzqRQfXDKKiPw3GJY"},
+			want: []string{
+				"play_media map[entity_id:media_player.office media_content_id:media-source://media_source/local/alert.mp3 media_content_type:music]",
+			},
+		},
+		{
+			name:    "Spotify media",
+			media:   "media-source://media_source/local/alert.mp3",
+			spotify: true,
+			song:    &alertify.Song{URI: "spotify:track:7yTIKQzqRQfXDKKiPw3GJY"},
+			want: []string{
+				"play_media map[entity_id:media_player.office media_content_id:spotify:track:7yTIKQzqRQfXDKKiPw3GJY media_content_type:music]",
+			},
+		},
+		{
+			name: "media source song",
+			song: &alertify.Song{URI: "media-source://media_source/local/siren.mp3"},
+			want: []string{
+				"play_media map[entity_id:media_player.office media_content_id:media-source://media_source/local/siren.mp3 media_content_type:music]",
+			},
+		},
+		{
+			name: "stream song",
+			song: &alertify.Song{URI: "https://alerts.example.com/siren.mp3"},
+			want: []string{
+				"play_media map[entity_id:media_player.office media_content_id:https://alerts.example.com/siren.mp3 media_content_type:music]",
+			},
+		},
+		{
+			name:  "volume",
+			media: "media-source://media_source/local/alert.mp3",
+			song:  &alertify.Song{Volume: 40},
+			want: []string{
+				"volume_set map[entity_id:media_player.office volume_level:0.4]",
+				"play_media map[entity_id:media_player.office media_content_id:media-source://media_source/local/alert.mp3 media_content_type:music]",
+			},
+		},
+		{
+			name:  "relative volume",
+			media: "media-source://media_source/local/alert.mp3",
+			state: `{"entity_id": "media_player.office", "state": "idle", "attributes": {"volume_level": 0.3}}`,
+			song:  &alertify.Song{Volume: 25, RelativeVolume: true},
+			want: []string{
+				"volume_set map[entity_id:media_player.office volume_level:0.55]",
+				"play_media map[entity_id:media_player.office media_content_id:media-source://media_source/local/alert.mp3 media_content_type:music]",
+			},
+		},
+		{
+			name:  "relative volume down",
+			media: "media-source://media_source/local/alert.mp3",
+			state: `{"entity_id": "media_player.office", "state": "idle", "attributes": {"volume_level": 0.3}}`,
+			song:  &alertify.Song{Volume: -50, RelativeVolume: true},
+			want: []string{
+				"volume_set map[entity_id:media_player.office volume_level:0]",
+				"play_media map[entity_id:media_player.office media_content_id:media-source://media_source/local/alert.mp3 media_content_type:music]",
+			},
+		},
+		{
+			name:  "relative volume unavailable still plays",
+			media: "media-source://media_source/local/alert.mp3",
+			state: `{"entity_id": "media_player.office", "state": "off", "attributes": {}}`,
+			song:  &alertify.Song{Volume: 25, RelativeVolume: true},
+			want: []string{
+				"play_media map[entity_id:media_player.office media_content_id:media-source://media_source/local/alert.mp3 media_content_type:music]",
+			},
+		},
+		{
+			name:  "play failure",
+			media: "media-source://media_source/local/alert.mp3",
+			fail:  "play_media",
+			want: []string{
+				"play_media map[entity_id:media_player.office media_content_id:media-source://media_source/local/alert.mp3 media_content_type:music]",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no media",
+			song:    &alertify.Song{URI: "spotify:track:7yTIKQzqRQfXDKKiPw3GJY"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newFakeHomeAssistant(t, tt.state, tt.fail)
+
+			p, err := NewHomeAssistantPlayer(&HomeAssistantConfig{
+				URL:          h.srv.URL + "/",
+				Token:        testHAToken,
+				Entity:       "media_player.office",
+				Media:        tt.media,
+				SpotifyMedia: tt.spotify,
+			})
+			if err != nil {
+				t.Fatalf("failed to create Home Assistant player: %v", err)
+			}
+
+			err = p.Play(tt.song)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Play() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := h.called(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("calls = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHomeAssistantPlayerStop(t *testing.T) {
+	h := newFakeHomeAssistant(t, "", "")
+
+	p, err := NewHomeAssistantPlayer(&HomeAssistantConfig{
+		URL:    h.srv.URL,
+		Token:  testHAToken,
+		Entity: "media_player.office",
+	})
+	if err != nil {
+		t.Fatalf("failed to create Home Assistant player: %v", err)
+	}
+
+	if err := p.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	want := []string{"media_stop map[entity_id:media_player.office]"}
+	if got := h.called(); !reflect.DeepEqual(got, want) {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+}
+
+func TestNewHomeAssistantPlayer(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *HomeAssistantConfig
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			config: &HomeAssistantConfig{Token: testHAToken, Entity: "media_player.office"},
+		},
+		{
+			name:    "missing token",
+			config:  &HomeAssistantConfig{Entity: "media_player.office"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid entity",
+			config:  &HomeAssistantConfig{Token: testHAToken, Entity: "light.office"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHomeAssistantPlayer(tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("NewHomeAssistantPlayer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
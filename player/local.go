@@ -0,0 +1,264 @@
+package player
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/milosgajdos/alertify"
+)
+
+const (
+	// ALSA plays audio files through ALSA device
+	ALSA = "alsa"
+	// PulseAudio plays audio files through PulseAudio sink
+	PulseAudio = "pulse"
+	// FilePlaceholder is replaced with audio file path in LocalConfig.Command
+	FilePlaceholder = "{file}"
+)
+
+// LocalConfig configures local audio file player
+type LocalConfig struct {
+	// File is the audio file played when the song doesn't point to a local audio file
+	File string
+	// Command is the command used to play audio files.
+	// FilePlaceholder in command arguments is replaced with the audio file path.
+	// If Command is empty, the player picks a command based on Output and the audio file format.
+	Command []string
+	// Output is either ALSA or PulseAudio. It defaults to PulseAudio.
+	Output string
+	// Sink is the name of ALSA device or PulseAudio sink. Empty Sink uses the default one.
+	Sink string
+}
+
+// LocalPlayer plays local WAV, MP3 and OGG audio files
+type LocalPlayer struct {
+	// file is the default audio file
+	file string
+	// command is the command used to play audio files
+	command []string
+	// output is the audio output
+	output string
+	// sink is the audio output device or sink
+	sink string
+	// cmd is the running player command
+	cmd *exec.Cmd
+	// mutex
+	*sync.Mutex
+}
+
+// NewLocalPlayer creates new local audio file player and returns it
+// It returns error if the configured output is not supported.
+func NewLocalPlayer(c *LocalConfig) (*LocalPlayer, error) {
+	output := c.Output
+	if output == "" {
+		output = PulseAudio
+	}
+
+	if output != ALSA && output != PulseAudio {
+		return nil, fmt.Errorf("unsupported audio output: %s", output)
+	}
+
+	return &LocalPlayer{
+		file:    c.File,
+		command: c.Command,
+		output:  output,
+		sink:    c.Sink,
+		Mutex:   &sync.Mutex{},
+	}, nil
+}
+
+// String returns the name of the player
+func (p *LocalPlayer) String() string {
+	return "Local Player"
+}
+
+// IsLocalFile returns true if uri points to a local audio file
+func IsLocalFile(uri string) bool {
+	if strings.HasPrefix(uri, "file://") {
+		return true
+	}
+
+	switch strings.ToLower(filepath.Ext(uri)) {
+	case ".wav", ".mp3", ".ogg":
+		return !strings.Contains(uri, "://")
+	}
+
+	return false
+}
+
+// audioFile returns the path to the audio file song should be played from
+func (p *LocalPlayer) audioFile(song *alertify.Song) (string, error) {
+	file := p.file
+	if song != nil && IsLocalFile(song.URI) {
+		file = strings.TrimPrefix(song.URI, "file://")
+	}
+
+	if file == "" {
+		return "", fmt.Errorf("no audio file to play")
+	}
+
+	if _, err := os.Stat(file); err != nil {
+		return "", err
+	}
+
+	return file, nil
+}
+
+// commandArgs returns the command which plays audio file
+func (p *LocalPlayer) commandArgs(file string) ([]string, error) {
+	if len(p.command) > 0 {
+		args := make([]string, len(p.command))
+		for i, arg := range p.command {
+			args[i] = strings.Replace(arg, FilePlaceholder, file, -1)
+		}
+		return args, nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(file))
+
+	switch p.output {
+	case ALSA:
+		switch ext {
+		case ".wav":
+			return withSink([]string{"aplay", "-q"}, "-D", p.sink, file), nil
+		case ".mp3":
+			return withSink([]string{"mpg123", "-q", "-o", "alsa"}, "-a", p.sink, file), nil
+		case ".ogg":
+			args := []string{"ogg123", "-q", "-d", "alsa"}
+			if p.sink != "" {
+				args = append(args, "-o", "dev:"+p.sink)
+			}
+			return append(args, file), nil
+		}
+	case PulseAudio:
+		switch ext {
+		case ".wav", ".ogg":
+			args := []string{"paplay"}
+			if p.sink != "" {
+				args = append(args, "--device="+p.sink)
+			}
+			return append(args, file), nil
+		case ".mp3":
+			return withSink([]string{"mpg123", "-q", "-o", "pulse"}, "-a", p.sink, file), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported audio file format: %s", file)
+}
+
+// withSink appends sink flag to args if sink is not empty and appends file
+func withSink(args []string, flag, sink, file string) []string {
+	if sink != "" {
+		args = append(args, flag, sink)
+	}
+
+	return append(args, file)
+}
+
+// Play starts playing song audio file
+// If the song URI does not point to a local audio file, the configured audio file is played.
+// Any audio file which is still playing is stopped first.
+func (p *LocalPlayer) Play(song *alertify.Song) error {
+	cmd, err := p.start(song)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := p.wait(cmd); err != nil {
+			log.Printf("%s finished: %s", cmd.Path, err)
+		}
+	}()
+
+	return nil
+}
+
+// PlayAndWait plays song audio file and returns once it finishes playing
+func (p *LocalPlayer) PlayAndWait(song *alertify.Song) error {
+	cmd, err := p.start(song)
+	if err != nil {
+		return err
+	}
+
+	return p.wait(cmd)
+}
+
+// start starts the command which plays song audio file
+func (p *LocalPlayer) start(song *alertify.Song) (*exec.Cmd, error) {
+	file, err := p.audioFile(song)
+	if err != nil {
+		return nil, err
+	}
+
+	args, err := p.commandArgs(file)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.stop()
+
+	cmd := exec.Command(args[0], args[1:]...)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %s", args[0], err)
+	}
+	p.cmd = cmd
+
+	log.Printf("Playing %s with %s", file, args[0])
+
+	return cmd, nil
+}
+
+// wait waits for the player command to finish
+func (p *LocalPlayer) wait(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+
+	p.Lock()
+	if p.cmd == cmd {
+		p.cmd = nil
+	}
+	p.Unlock()
+
+	return err
+}
+
+// Stop stops the audio file playback
+func (p *LocalPlayer) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.stop()
+
+	return nil
+}
+
+// stopCmd kills cmd if it is the running player command
+func (p *LocalPlayer) stopCmd(cmd *exec.Cmd) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.cmd == cmd {
+		p.stop()
+	}
+}
+
+// stop kills the running player command
+// It must be called with player lock held.
+func (p *LocalPlayer) stop() {
+	if p.cmd == nil || p.cmd.Process == nil {
+		return
+	}
+
+	if err := p.cmd.Process.Kill(); err != nil {
+		// process has most likely finished in the meantime
+		log.Printf("Failed to stop %s: %s", p.cmd.Path, err)
+	}
+	p.cmd = nil
+}
@@ -0,0 +1,287 @@
+package player
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/milosgajdos/alertify"
+)
+
+const (
+	// DefaultMPDAddr is the default MPD server address
+	DefaultMPDAddr = "localhost:6600"
+	// DefaultMPDTimeout is the default MPD connection timeout
+	DefaultMPDTimeout = 5 * time.Second
+)
+
+// MPDConfig configures MPD player
+type MPDConfig struct {
+	// Addr is MPD server address. It defaults to DefaultMPDAddr.
+	Addr string
+	// Password is MPD server password
+	Password string
+	// Track is the URI of the track in MPD database played when the song is a Spotify song
+	Track string
+	// Timeout is the connection timeout. It defaults to DefaultMPDTimeout.
+	Timeout time.Duration
+}
+
+// MPDPlayer plays alert tracks on Music Player Daemon
+type MPDPlayer struct {
+	// addr is MPD server address
+	addr string
+	// password is MPD server password
+	password string
+	// track is the default track
+	track string
+	// timeout is the connection timeout
+	timeout time.Duration
+	// mutex
+	*sync.Mutex
+}
+
+// NewMPDPlayer creates new MPD player and returns it
+func NewMPDPlayer(c *MPDConfig) (*MPDPlayer, error) {
+	addr := c.Addr
+	if addr == "" {
+		addr = DefaultMPDAddr
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid MPD address %s: %s", addr, err)
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultMPDTimeout
+	}
+
+	return &MPDPlayer{
+		addr:     addr,
+		password: c.Password,
+		track:    c.Track,
+		timeout:  timeout,
+		Mutex:    &sync.Mutex{},
+	}, nil
+}
+
+// String returns the name of the player
+func (p *MPDPlayer) String() string {
+	return fmt.Sprintf("MPD Player (%s)", p.addr)
+}
+
+// mpdTrack returns the URI of MPD track song should be played from
+func (p *MPDPlayer) mpdTrack(song *alertify.Song) (string, error) {
+	track := p.track
+	if song != nil && song.URI != "" && !alertify.IsSpotifyURI(song.URI) {
+		track = song.URI
+	}
+
+	if track == "" {
+		return "", fmt.Errorf("no MPD track to play")
+	}
+
+	return track, nil
+}
+
+// Play replaces MPD queue with song track and starts playing it
+// If the song is a Spotify song, the configured track is played.
+func (p *MPDPlayer) Play(song *alertify.Song) error {
+	track, err := p.mpdTrack(song)
+	if err != nil {
+		return err
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	conn, err := p.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// negative volume leaves MPD volume unchanged
+	volume := -1
+	if song != nil && song.Volume != 0 {
+		volume = song.Volume
+		if song.RelativeVolume {
+			status, err := conn.status()
+			if err != nil {
+				return err
+			}
+			current, err := strconv.Atoi(status["volume"])
+			if err != nil {
+				return fmt.Errorf("MPD volume not available: %s", status["volume"])
+			}
+			volume += current
+		}
+		volume = alertify.ClampVolume(volume)
+	}
+
+	arg, err := quoteArg(track)
+	if err != nil {
+		return err
+	}
+
+	cmds := []string{"clear", "add " + arg}
+	if volume >= 0 {
+		cmds = append(cmds, fmt.Sprintf("setvol %d", volume))
+	}
+	cmds = append(cmds, "play 0")
+	if song != nil && song.PositionMs > 0 {
+		cmds = append(cmds, fmt.Sprintf("seekcur %.3f", float64(song.PositionMs)/1000))
+	}
+
+	if _, err := conn.commandList(cmds); err != nil {
+		return err
+	}
+
+	log.Printf("Playing %s on %s", track, p)
+
+	return nil
+}
+
+// Stop stops MPD playback
+func (p *MPDPlayer) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	conn, err := p.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.command("stop")
+
+	return err
+}
+
+// dial connects to MPD server and authenticates if password is configured
+func (p *MPDPlayer) dial() (*mpdConn, error) {
+	c, err := net.DialTimeout("tcp", p.addr, p.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MPD: %s", err)
+	}
+
+	conn := &mpdConn{
+		Conn:    c,
+		r:       bufio.NewReader(c),
+		timeout: p.timeout,
+	}
+
+	if err := conn.hello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if p.password != "" {
+		arg, err := quoteArg(p.password)
+		if err == nil {
+			_, err = conn.command("password " + arg)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// mpdConn is MPD protocol connection
+type mpdConn struct {
+	net.Conn
+	// r reads server responses
+	r *bufio.Reader
+	// timeout is the command timeout
+	timeout time.Duration
+}
+
+// hello reads MPD server greeting
+func (c *mpdConn) hello() error {
+	if err := c.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return fmt.Errorf("failed to set MPD connection deadline: %s", err)
+	}
+
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read MPD greeting: %s", err)
+	}
+
+	if !strings.HasPrefix(line, "OK MPD ") {
+		return fmt.Errorf("unexpected MPD greeting: %s", strings.TrimSpace(line))
+	}
+
+	return nil
+}
+
+// command sends cmd to MPD server and returns response key value pairs
+func (c *mpdConn) command(cmd string) (map[string]string, error) {
+	if err := c.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, fmt.Errorf("failed to set MPD connection deadline: %s", err)
+	}
+
+	if _, err := fmt.Fprintf(c, "%s\n", cmd); err != nil {
+		return nil, fmt.Errorf("failed to send MPD command: %s", err)
+	}
+
+	return c.response()
+}
+
+// commandList sends cmds to MPD server as a single command list
+func (c *mpdConn) commandList(cmds []string) (map[string]string, error) {
+	list := append([]string{"command_list_begin"}, cmds...)
+	list = append(list, "command_list_end")
+
+	return c.command(strings.Join(list, "\n"))
+}
+
+// status returns MPD status
+func (c *mpdConn) status() (map[string]string, error) {
+	return c.command("status")
+}
+
+// response reads MPD response until OK or ACK line
+func (c *mpdConn) response() (map[string]string, error) {
+	resp := make(map[string]string)
+
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("failed to read MPD response: %s", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "OK":
+			return resp, nil
+		case strings.HasPrefix(line, "ACK "):
+			return nil, fmt.Errorf("MPD error: %s", strings.TrimPrefix(line, "ACK "))
+		}
+
+		if i := strings.Index(line, ": "); i > 0 {
+			resp[line[:i]] = line[i+2:]
+		}
+	}
+}
+
+// quoteArg quotes MPD command argument
+// It returns error if arg contains line breaks, which would end the command.
+func quoteArg(arg string) (string, error) {
+	if strings.ContainsAny(arg, "\r\n") {
+		return "", fmt.Errorf("invalid MPD argument: %q", arg)
+	}
+
+	arg = strings.Replace(arg, `\`, `\\`, -1)
+	arg = strings.Replace(arg, `"`, `\"`, -1)
+
+	return `"` + arg + `"`, nil
+}
@@ -0,0 +1,301 @@
+package player
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/milosgajdos/alertify"
+)
+
+// fakeMPD is a fake MPD server which records received commands
+type fakeMPD struct {
+	// l listens for MPD connections
+	l net.Listener
+	// password is the server password
+	password string
+	// volume is the volume reported by status command
+	volume string
+	// cmds are the received commands
+	cmds []string
+	// mutex
+	*sync.Mutex
+}
+
+// newFakeMPD starts fake MPD server and returns it
+func newFakeMPD(t *testing.T, password, volume string) *fakeMPD {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	s := &fakeMPD{
+		l:        l,
+		password: password,
+		volume:   volume,
+		Mutex:    &sync.Mutex{},
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	t.Cleanup(func() { l.Close() })
+
+	return s
+}
+
+// addr returns the server address
+func (s *fakeMPD) addr() string {
+	return s.l.Addr().String()
+}
+
+// commands returns the received commands
+func (s *fakeMPD) commands() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	return append([]string(nil), s.cmds...)
+}
+
+// serve serves MPD connection
+func (s *fakeMPD) serve(conn net.Conn) {
+	defer conn.Close()
+
+	fmt.Fprint(conn, "OK MPD 0.23.5\n")
+
+	r := bufio.NewReader(conn)
+	inList, listErr := false, ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSuffix(line, "\n")
+
+		switch {
+		case cmd == "command_list_begin":
+			inList = true
+			continue
+		case cmd == "command_list_end":
+			if listErr != "" {
+				fmt.Fprint(conn, listErr)
+			} else {
+				fmt.Fprint(conn, "OK\n")
+			}
+			inList, listErr = false, ""
+			continue
+		case strings.HasPrefix(cmd, "password "):
+			if arg, _ := quoteArg(s.password); cmd != "password "+arg {
+				fmt.Fprint(conn, "ACK [3@0] {password} incorrect password\n")
+				continue
+			}
+		}
+
+		s.Lock()
+		s.cmds = append(s.cmds, cmd)
+		s.Unlock()
+
+		switch {
+		case strings.HasPrefix(cmd, "add ") && strings.Contains(cmd, "missing"):
+			if inList {
+				listErr = "ACK [50@1] {add} No such directory\n"
+			} else {
+				fmt.Fprint(conn, "ACK [50@0] {add} No such directory\n")
+			}
+		case inList:
+		case cmd == "status":
+			fmt.Fprintf(conn, "volume: %s\nstate: stop\nOK\n", s.volume)
+		default:
+			fmt.Fprint(conn, "OK\n")
+		}
+	}
+}
+
+func TestMPDPlayerPlay(t *testing.T) {
+	tests := []struct {
+		name     string
+		track    string
+		password string
+		volume   string
+		song     *alertify.Song
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:  "default track",
+			track: "alerts/default.mp3",
+			want:  []string{"clear", `add "alerts/default.mp3"`, "play 0"},
+		},
+		{
+			name:  "Spotify URI plays default track",
+			track: "alerts/default.mp3",
+			song:  &alertify.Song{URI: "spotify:track:7yTIKQzqRQfXDKKiPw3GJY"},
+			want:  []string{"clear", `add "alerts/default.mp3"`, "play 0"},
+		},
+		{
+			name:  "Spotify URL plays default track",
+			track: "alerts/default.mp3",
+			song:  &alertify.Song{URI: "https://open.spotify.com/track/7yTIKQzqRQfXDKKiPw3GJY"},
+			want:  []string{"clear", `add "alerts/default.mp3"`, "play 0"},
+		},
+		{
+			name:  "song track",
+			track: "alerts/default.mp3",
+			song:  &alertify.Song{URI: "alerts/siren.mp3"},
+			want:  []string{"clear", `add "alerts/siren.mp3"`, "play 0"},
+		},
+		{
+			name: "song stream",
+			song: &alertify.Song{URI: "http://radio.example.com/stream"},
+			want: []string{"clear", `add "http://radio.example.com/stream"`, "play 0"},
+		},
+		{
+			name: "quoted track",
+			song: &alertify.Song{URI: `alerts/"red" alert.mp3`},
+			want: []string{"clear", `add "alerts/\"red\" alert.mp3"`, "play 0"},
+		},
+		{
+			name:  "volume and position",
+			track: "alerts/default.mp3",
+			song:  &alertify.Song{Volume: 120, PositionMs: 1500},
+			want:  []string{"clear", `add "alerts/default.mp3"`, "setvol 100", "play 0", "seekcur 1.500"},
+		},
+		{
+			name:   "relative volume",
+			track:  "alerts/default.mp3",
+			volume: "50",
+			song:   &alertify.Song{Volume: 20, RelativeVolume: true},
+			want:   []string{"status", "clear", `add "alerts/default.mp3"`, "setvol 70", "play 0"},
+		},
+		{
+			name:   "relative volume down to mute",
+			track:  "alerts/default.mp3",
+			volume: "50",
+			song:   &alertify.Song{Volume: -60, RelativeVolume: true},
+			want:   []string{"status", "clear", `add "alerts/default.mp3"`, "setvol 0", "play 0"},
+		},
+		{
+			name:    "relative volume unavailable",
+			track:   "alerts/default.mp3",
+			song:    &alertify.Song{Volume: 20, RelativeVolume: true},
+			want:    []string{"status"},
+			wantErr: true,
+		},
+		{
+			name:    "track with line break",
+			song:    &alertify.Song{URI: "alerts/siren.mp3\nclear"},
+			wantErr: true,
+		},
+		{
+			name:     "password",
+			track:    "alerts/default.mp3",
+			password: "secret",
+			want:     []string{`password "secret"`, "clear", `add "alerts/default.mp3"`, "play 0"},
+		},
+		{
+			name:    "no track",
+			wantErr: true,
+		},
+		{
+			name:    "missing track",
+			track:   "alerts/missing.mp3",
+			want:    []string{"clear", `add "alerts/missing.mp3"`, "play 0"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeMPD(t, tt.password, tt.volume)
+
+			p, err := NewMPDPlayer(&MPDConfig{
+				Addr:     server.addr(),
+				Password: tt.password,
+				Track:    tt.track,
+			})
+			if err != nil {
+				t.Fatalf("failed to create MPD player: %v", err)
+			}
+
+			err = p.Play(tt.song)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Play() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := server.commands(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("commands = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMPDPlayerStop(t *testing.T) {
+	server := newFakeMPD(t, "", "")
+
+	p, err := NewMPDPlayer(&MPDConfig{Addr: server.addr()})
+	if err != nil {
+		t.Fatalf("failed to create MPD player: %v", err)
+	}
+
+	if err := p.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	if got, want := server.commands(), []string{"stop"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestMPDPlayerWrongPassword(t *testing.T) {
+	server := newFakeMPD(t, "secret", "")
+
+	p, err := NewMPDPlayer(&MPDConfig{Addr: server.addr(), Password: "wrong", Track: "alerts/default.mp3"})
+	if err != nil {
+		t.Fatalf("failed to create MPD player: %v", err)
+	}
+
+	if err := p.Play(nil); err == nil {
+		t.Fatal("Play() succeeded with wrong password")
+	}
+
+	if got := server.commands(); len(got) != 0 {
+		t.Errorf("commands = %q, want none", got)
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", arg: "alerts/siren.mp3", want: `"alerts/siren.mp3"`},
+		{name: "quotes and backslashes", arg: `a\"b"`, want: `"a\\\"b\""`},
+		{name: "newline", arg: "siren.mp3\nstop", wantErr: true},
+		{name: "carriage return", arg: "siren.mp3\rstop", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := quoteArg(tt.arg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("quoteArg() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("quoteArg() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package player
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/milosgajdos/alertify"
+)
+
+const (
+	// TextPlaceholder is replaced with announcement text in TTSConfig.Command
+	TextPlaceholder = "{text}"
+)
+
+// DefaultTTSCommand synthesizes speech with espeak reading the text from stdin
+var DefaultTTSCommand = []string{"espeak", "-w", FilePlaceholder, "--stdin"}
+
+// TTSConfig configures text-to-speech announcer
+type TTSConfig struct {
+	// Command synthesizes speech into WAV file. It defaults to DefaultTTSCommand.
+	// FilePlaceholder in command arguments is replaced with the WAV file path
+	// and TextPlaceholder is replaced with the announcement text.
+	// If there is no TextPlaceholder in the command, the text is written to command stdin,
+	// e.g. piper --model en_US-lessac-medium.onnx --output_file {file}
+	// Text passed in arguments may start with a dash, so end the command options with --
+	// before TextPlaceholder, e.g. espeak -w {file} -- {text}
+	Command []string
+	// Player is the local player which plays the synthesized speech.
+	// Pass the local player registered with the bot, so that announcements
+	// and local alert songs never play over each other.
+	Player *LocalPlayer
+}
+
+// TTS announces alerts with local text-to-speech engine
+type TTS struct {
+	// command synthesizes speech
+	command []string
+	// player plays synthesized speech
+	player *LocalPlayer
+	// cmd is the running speech synthesis command
+	cmd *exec.Cmd
+	// speech is the player command playing the synthesized speech
+	speech *exec.Cmd
+	// stops counts stopped announcements
+	stops int
+	// mutex
+	*sync.Mutex
+}
+
+// NewTTS creates new text-to-speech announcer and returns it
+// It returns error if no local player is configured.
+func NewTTS(c *TTSConfig) (*TTS, error) {
+	command := c.Command
+	if len(command) == 0 {
+		command = DefaultTTSCommand
+	}
+
+	if c.Player == nil {
+		return nil, fmt.Errorf("no local player to play announcements")
+	}
+
+	return &TTS{
+		command: command,
+		player:  c.Player,
+		Mutex:   &sync.Mutex{},
+	}, nil
+}
+
+// String returns the name of the announcer
+func (t *TTS) String() string {
+	return fmt.Sprintf("TTS Announcer (%s)", t.command[0])
+}
+
+// Announce synthesizes text into speech and plays it
+// It returns once the speech finishes playing or once the announcement is stopped.
+func (t *TTS) Announce(text string) error {
+	t.Lock()
+	stops := t.stops
+	t.Unlock()
+
+	f, err := ioutil.TempFile("", "alertify-*.wav")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	if err := t.synthesize(text, f.Name()); err != nil {
+		return err
+	}
+
+	// the speech is started with the lock held, so Stop either prevents it or stops it
+	t.Lock()
+	if t.stops != stops {
+		t.Unlock()
+		return fmt.Errorf("announcement stopped")
+	}
+	speech, err := t.player.start(&alertify.Song{URI: f.Name()})
+	if err != nil {
+		t.Unlock()
+		return err
+	}
+	t.speech = speech
+	t.Unlock()
+
+	err = t.player.wait(speech)
+
+	t.Lock()
+	if t.speech == speech {
+		t.speech = nil
+	}
+	t.Unlock()
+
+	return err
+}
+
+// synthesize synthesizes text into WAV file
+func (t *TTS) synthesize(text, file string) error {
+	stdin := true
+	args := make([]string, len(t.command))
+	for i, arg := range t.command {
+		if strings.Contains(arg, TextPlaceholder) {
+			stdin = false
+		}
+		arg = strings.Replace(arg, FilePlaceholder, file, -1)
+		args[i] = strings.Replace(arg, TextPlaceholder, text, -1)
+	}
+
+	var out bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = &out, &out
+	if stdin {
+		cmd.Stdin = strings.NewReader(text)
+	}
+
+	t.Lock()
+	if err := cmd.Start(); err != nil {
+		t.Unlock()
+		return fmt.Errorf("failed to start %s: %s", args[0], err)
+	}
+	t.cmd = cmd
+	t.Unlock()
+
+	log.Printf("Synthesizing announcement with %s", args[0])
+
+	err := cmd.Wait()
+
+	t.Lock()
+	if t.cmd == cmd {
+		t.cmd = nil
+	}
+	t.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("%s failed: %s: %s", args[0], err, strings.TrimSpace(out.String()))
+	}
+
+	return nil
+}
+
+// Stop stops the announcement
+// Audio played by the local player which is not the announcement keeps playing.
+func (t *TTS) Stop() error {
+	t.Lock()
+	defer t.Unlock()
+
+	t.stops++
+
+	if t.cmd != nil && t.cmd.Process != nil {
+		if err := t.cmd.Process.Kill(); err != nil {
+			log.Printf("Failed to stop %s: %s", t.cmd.Path, err)
+		}
+		t.cmd = nil
+	}
+
+	if t.speech != nil {
+		t.player.stopCmd(t.speech)
+		t.speech = nil
+	}
+
+	return nil
+}
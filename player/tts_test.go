@@ -0,0 +1,136 @@
+package player
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/milosgajdos/alertify"
+)
+
+// newTestTTS creates TTS announcer synthesizing speech with command and playing it with playCommand
+func newTestTTS(t *testing.T, command, playCommand []string) (*TTS, *LocalPlayer) {
+	local, err := NewLocalPlayer(&LocalConfig{Command: playCommand})
+	if err != nil {
+		t.Fatalf("failed to create local player: %v", err)
+	}
+
+	tts, err := NewTTS(&TTSConfig{Command: command, Player: local})
+	if err != nil {
+		t.Fatalf("failed to create TTS: %v", err)
+	}
+
+	return tts, local
+}
+
+func TestNewTTSNoPlayer(t *testing.T) {
+	if _, err := NewTTS(&TTSConfig{}); err == nil {
+		t.Fatal("NewTTS() succeeded without local player")
+	}
+}
+
+func TestTTSAnnounce(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		want    string
+	}{
+		{
+			name:    "text in arguments",
+			command: []string{"sh", "-c", `printf '%s' "$0" > {file}`, TextPlaceholder},
+			want:    "alert firing",
+		},
+		{
+			name:    "text on stdin",
+			command: []string{"sh", "-c", "cat > {file}"},
+			want:    "alert firing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "alertify")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.RemoveAll(dir) })
+
+			// player copies the speech so its content can be checked
+			out := filepath.Join(dir, "speech.wav")
+			tts, _ := newTestTTS(t, tt.command, []string{"cp", FilePlaceholder, out})
+
+			if err := tts.Announce("alert firing"); err != nil {
+				t.Fatalf("Announce() error = %v", err)
+			}
+
+			got, err := ioutil.ReadFile(out)
+			if err != nil {
+				t.Fatalf("speech not played: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("played speech = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTTSStop(t *testing.T) {
+	tts, local := newTestTTS(t, []string{"sh", "-c", "cat > {file}"}, []string{"sleep", "5"})
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- tts.Announce("alert firing")
+	}()
+
+	// wait for the speech to start playing
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		tts.Lock()
+		playing := tts.speech != nil
+		tts.Unlock()
+		if playing {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("speech did not start playing")
+		}
+	}
+
+	if err := tts.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	select {
+	case err := <-errChan:
+		if err == nil {
+			t.Error("stopped Announce() succeeded")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Announce() did not return once stopped")
+	}
+
+	// stopping the announcement leaves other local playback alone
+	f, err := ioutil.TempFile("", "alertify-*.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	t.Cleanup(func() { os.Remove(f.Name()) })
+
+	if err := local.Play(&alertify.Song{URI: f.Name()}); err != nil {
+		t.Fatalf("Play() error = %v", err)
+	}
+	t.Cleanup(func() { local.Stop() })
+
+	if err := tts.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	local.Lock()
+	playing := local.cmd != nil
+	local.Unlock()
+	if !playing {
+		t.Error("Stop() stopped local playback which is not the announcement")
+	}
+}
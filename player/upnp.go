@@ -0,0 +1,488 @@
+package player
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/milosgajdos/alertify"
+)
+
+const (
+	// DefaultSSDPAddr is the default SSDP multicast address
+	DefaultSSDPAddr = "239.255.255.250:1900"
+	// DefaultUPnPTimeout is the default UPnP discovery and request timeout
+	DefaultUPnPTimeout = 5 * time.Second
+	// AVTransport is UPnP AVTransport service type
+	AVTransport = "urn:schemas-upnp-org:service:AVTransport:1"
+	// RenderingControl is UPnP RenderingControl service type
+	RenderingControl = "urn:schemas-upnp-org:service:RenderingControl:1"
+)
+
+// UPnPConfig configures UPnP media renderer player
+type UPnPConfig struct {
+	// Location is the renderer device description URL.
+	// If Location is empty, the renderer is discovered via SSDP.
+	Location string
+	// Name is the friendly name of the renderer, e.g. Sonos room name.
+	// Empty Name picks the first discovered renderer.
+	Name string
+	// StreamURL is the audio stream played when the song is not an http(s) URL
+	StreamURL string
+	// SSDPAddr is SSDP discovery address. It defaults to DefaultSSDPAddr.
+	SSDPAddr string
+	// Timeout is discovery and request timeout. It defaults to DefaultUPnPTimeout.
+	Timeout time.Duration
+}
+
+// UPnPPlayer plays alert streams on UPnP/DLNA media renderers such as Sonos speakers
+type UPnPPlayer struct {
+	// location is the configured device description URL
+	location string
+	// name is the renderer friendly name
+	name string
+	// streamURL is the default audio stream
+	streamURL string
+	// ssdpAddr is SSDP discovery address
+	ssdpAddr string
+	// client sends SOAP requests
+	client *http.Client
+	// timeout is discovery timeout
+	timeout time.Duration
+	// renderer is the discovered media renderer
+	renderer *upnpRenderer
+	// mutex
+	*sync.Mutex
+}
+
+// upnpRenderer is UPnP media renderer
+type upnpRenderer struct {
+	// name is renderer friendly name
+	name string
+	// avTransport is AVTransport control URL
+	avTransport string
+	// renderingControl is RenderingControl control URL
+	renderingControl string
+}
+
+// NewUPnPPlayer creates new UPnP media renderer player and returns it
+func NewUPnPPlayer(c *UPnPConfig) (*UPnPPlayer, error) {
+	ssdpAddr := c.SSDPAddr
+	if ssdpAddr == "" {
+		ssdpAddr = DefaultSSDPAddr
+	}
+
+	if _, err := net.ResolveUDPAddr("udp4", ssdpAddr); err != nil {
+		return nil, fmt.Errorf("invalid SSDP address %s: %s", ssdpAddr, err)
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultUPnPTimeout
+	}
+
+	return &UPnPPlayer{
+		location:  c.Location,
+		name:      c.Name,
+		streamURL: c.StreamURL,
+		ssdpAddr:  ssdpAddr,
+		client:    &http.Client{Timeout: timeout},
+		timeout:   timeout,
+		Mutex:     &sync.Mutex{},
+	}, nil
+}
+
+// String returns the name of the player
+func (p *UPnPPlayer) String() string {
+	if p.name != "" {
+		return fmt.Sprintf("UPnP Player (%s)", p.name)
+	}
+
+	return "UPnP Player"
+}
+
+// stream returns the URL of the stream song should be played from
+func (p *UPnPPlayer) stream(song *alertify.Song) (string, error) {
+	stream := p.streamURL
+	if song != nil && (strings.HasPrefix(song.URI, "http://") || strings.HasPrefix(song.URI, "https://")) &&
+		!alertify.IsSpotifyURI(song.URI) {
+		stream = song.URI
+	}
+
+	if stream == "" {
+		return "", fmt.Errorf("no stream URL to play")
+	}
+
+	return stream, nil
+}
+
+// Play plays song stream on the media renderer
+// If the song is not an http(s) URL, the configured stream URL is played.
+func (p *UPnPPlayer) Play(song *alertify.Song) error {
+	stream, err := p.stream(song)
+	if err != nil {
+		return err
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	r, err := p.discover()
+	if err != nil {
+		return err
+	}
+
+	if err := p.play(r, song, stream); err != nil {
+		// renderer might have changed its address so discover it again next time
+		p.renderer = nil
+		return err
+	}
+
+	log.Printf("Playing %s on %s", stream, r.name)
+
+	return nil
+}
+
+// play sets renderer volume and transport URI and starts the playback
+func (p *UPnPPlayer) play(r *upnpRenderer, song *alertify.Song, stream string) error {
+	if song != nil && song.Volume != 0 && r.renderingControl != "" {
+		if err := p.setVolume(r, song); err != nil {
+			log.Printf("Failed to set volume on %s: %s", r.name, err)
+		}
+	}
+
+	if _, err := p.soap(r.avTransport, AVTransport, "SetAVTransportURI", [][2]string{
+		{"InstanceID", "0"},
+		{"CurrentURI", stream},
+		{"CurrentURIMetaData", ""},
+	}); err != nil {
+		return err
+	}
+
+	_, err := p.soap(r.avTransport, AVTransport, "Play", [][2]string{
+		{"InstanceID", "0"},
+		{"Speed", "1"},
+	})
+
+	return err
+}
+
+// setVolume sets renderer volume to song volume
+// Relative song volume is added to the current renderer volume.
+func (p *UPnPPlayer) setVolume(r *upnpRenderer, song *alertify.Song) error {
+	volume := song.Volume
+	if song.RelativeVolume {
+		current, err := p.volume(r)
+		if err != nil {
+			return err
+		}
+		volume += current
+	}
+
+	_, err := p.soap(r.renderingControl, RenderingControl, "SetVolume", [][2]string{
+		{"InstanceID", "0"},
+		{"Channel", "Master"},
+		{"DesiredVolume", fmt.Sprintf("%d", alertify.ClampVolume(volume))},
+	})
+
+	return err
+}
+
+// volume returns the current renderer volume
+func (p *UPnPPlayer) volume(r *upnpRenderer) (int, error) {
+	body, err := p.soap(r.renderingControl, RenderingControl, "GetVolume", [][2]string{
+		{"InstanceID", "0"},
+		{"Channel", "Master"},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		Volume string `xml:"Body>GetVolumeResponse>CurrentVolume"`
+	}
+
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return 0, fmt.Errorf("invalid UPnP GetVolume response: %s", err)
+	}
+
+	volume, err := strconv.Atoi(strings.TrimSpace(resp.Volume))
+	if err != nil {
+		return 0, fmt.Errorf("invalid UPnP volume: %q", resp.Volume)
+	}
+
+	return volume, nil
+}
+
+// Stop stops the playback on the media renderer
+func (p *UPnPPlayer) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	r, err := p.discover()
+	if err != nil {
+		return err
+	}
+
+	_, err = p.soap(r.avTransport, AVTransport, "Stop", [][2]string{
+		{"InstanceID", "0"},
+	})
+
+	return err
+}
+
+// discover returns the media renderer
+// It must be called with player lock held.
+func (p *UPnPPlayer) discover() (*upnpRenderer, error) {
+	if p.renderer != nil {
+		return p.renderer, nil
+	}
+
+	locations := []string{p.location}
+	if p.location == "" {
+		var err error
+		locations, err = p.search()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, location := range locations {
+		r, err := p.describe(location)
+		if err != nil {
+			log.Printf("Failed to describe UPnP device %s: %s", location, err)
+			continue
+		}
+
+		if r == nil || (p.name != "" && r.name != p.name) {
+			continue
+		}
+
+		p.renderer = r
+		return r, nil
+	}
+
+	if p.name != "" {
+		return nil, fmt.Errorf("UPnP renderer %s not found", p.name)
+	}
+
+	return nil, fmt.Errorf("no UPnP renderer found")
+}
+
+// search sends SSDP search for AVTransport services and returns discovered device description URLs
+func (p *UPnPPlayer) search() ([]string, error) {
+	addr, err := net.ResolveUDPAddr("udp4", p.ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start SSDP discovery: %s", err)
+	}
+	defer conn.Close()
+
+	mx := int(p.timeout / time.Second)
+	if mx < 1 {
+		mx = 1
+	}
+
+	req := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + p.ssdpAddr + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		fmt.Sprintf("MX: %d\r\n", mx) +
+		"ST: " + AVTransport + "\r\n\r\n"
+
+	if _, err := conn.WriteToUDP([]byte(req), addr); err != nil {
+		return nil, fmt.Errorf("failed to send SSDP search: %s", err)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(p.timeout)); err != nil {
+		return nil, fmt.Errorf("failed to set SSDP read deadline: %s", err)
+	}
+
+	var locations []string
+	seen := make(map[string]bool)
+	buf := make([]byte, 4096)
+
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// read deadline ends the discovery
+			break
+		}
+
+		location := ssdpHeader(string(buf[:n]), "LOCATION")
+		if location != "" && !seen[location] {
+			seen[location] = true
+			locations = append(locations, location)
+			// first renderer is good enough if we don't look for a specific one
+			if p.name == "" {
+				break
+			}
+		}
+	}
+
+	return locations, nil
+}
+
+// ssdpHeader returns the value of SSDP response header
+func ssdpHeader(resp, header string) string {
+	for _, line := range strings.Split(resp, "\r\n") {
+		i := strings.Index(line, ":")
+		if i > 0 && strings.EqualFold(strings.TrimSpace(line[:i]), header) {
+			return strings.TrimSpace(line[i+1:])
+		}
+	}
+
+	return ""
+}
+
+// upnpDevice is UPnP device description
+type upnpDevice struct {
+	FriendlyName string `xml:"friendlyName"`
+	Services     []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+// describe fetches device description and returns the media renderer it describes
+// It returns nil if the device doesn't provide AVTransport service.
+func (p *UPnPPlayer) describe(location string) (*upnpRenderer, error) {
+	resp, err := p.client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var root struct {
+		URLBase string     `xml:"URLBase"`
+		Device  upnpDevice `xml:"device"`
+	}
+
+	if err := xml.NewDecoder(resp.Body).Decode(&root); err != nil {
+		return nil, fmt.Errorf("invalid device description: %s", err)
+	}
+
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if root.URLBase != "" {
+		if base, err = url.Parse(root.URLBase); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sonos speakers provide AVTransport in MediaRenderer sub-device
+	// so we use the top level device friendly name for matching
+	r := &upnpRenderer{name: root.Device.FriendlyName}
+	findServices(&root.Device, base, r)
+
+	if r.avTransport == "" {
+		return nil, nil
+	}
+
+	return r, nil
+}
+
+// findServices looks up renderer control URLs in device and its sub-devices
+func findServices(d *upnpDevice, base *url.URL, r *upnpRenderer) {
+	for _, s := range d.Services {
+		ref, err := url.Parse(strings.TrimSpace(s.ControlURL))
+		if err != nil {
+			continue
+		}
+		controlURL := base.ResolveReference(ref).String()
+
+		switch strings.TrimSpace(s.ServiceType) {
+		case AVTransport:
+			if r.avTransport == "" {
+				r.avTransport = controlURL
+			}
+		case RenderingControl:
+			if r.renderingControl == "" {
+				r.renderingControl = controlURL
+			}
+		}
+	}
+
+	for i := range d.Devices {
+		findServices(&d.Devices[i], base, r)
+	}
+}
+
+// soap invokes action of service on controlURL with the given arguments and returns the response body
+func (p *UPnPPlayer) soap(controlURL, service, action string, args [][2]string) ([]byte, error) {
+	var body bytes.Buffer
+
+	body.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+	body.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, service)
+	for _, arg := range args {
+		fmt.Fprintf(&body, "<%s>", arg[0])
+		if err := xml.EscapeText(&body, []byte(arg[1])); err != nil {
+			return nil, fmt.Errorf("failed to encode %s argument %s: %s", action, arg[0], err)
+		}
+		fmt.Fprintf(&body, "</%s>", arg[0])
+	}
+	fmt.Fprintf(&body, `</u:%s>`, action)
+	body.WriteString(`</s:Body></s:Envelope>`)
+
+	req, err := http.NewRequest(http.MethodPost, controlURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPACTION", fmt.Sprintf(`"%s#%s"`, service, action))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("UPnP %s failed: %s", action, err)
+	}
+	defer resp.Body.Close()
+
+	msg, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("UPnP %s failed: %s", action, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("UPnP %s failed: %s: %s", action, resp.Status, soapFault(msg))
+	}
+
+	return msg, nil
+}
+
+// soapFault returns UPnP error description from SOAP fault response
+func soapFault(body []byte) string {
+	var fault struct {
+		Code        string `xml:"Body>Fault>detail>UPnPError>errorCode"`
+		Description string `xml:"Body>Fault>detail>UPnPError>errorDescription"`
+	}
+
+	if err := xml.Unmarshal(body, &fault); err != nil || fault.Code == "" {
+		return strings.TrimSpace(string(body))
+	}
+
+	if fault.Description == "" {
+		return "error " + fault.Code
+	}
+
+	return fmt.Sprintf("error %s: %s", fault.Code, fault.Description)
+}
@@ -0,0 +1,405 @@
+package player
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/milosgajdos/alertify"
+)
+
+// fakeRenderer is a fake UPnP media renderer which records received SOAP actions
+type fakeRenderer struct {
+	// srv serves device description and SOAP control URLs
+	srv *httptest.Server
+	// name is the renderer friendly name
+	name string
+	// volume is the volume returned by GetVolume action
+	volume int
+	// fail is the action which returns UPnP error
+	fail string
+	// actions are the received SOAP actions
+	actions []string
+	// mutex
+	*sync.Mutex
+}
+
+// newFakeRenderer starts fake UPnP media renderer and returns it
+func newFakeRenderer(t *testing.T, name string, volume int, fail string) *fakeRenderer {
+	r := &fakeRenderer{
+		name:   name,
+		volume: volume,
+		fail:   fail,
+		Mutex:  &sync.Mutex{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/description.xml", r.describe)
+	mux.HandleFunc("/MediaRenderer/AVTransport/Control", r.control)
+	mux.HandleFunc("/MediaRenderer/RenderingControl/Control", r.control)
+
+	r.srv = httptest.NewServer(mux)
+	t.Cleanup(r.srv.Close)
+
+	return r
+}
+
+// location returns the device description URL
+func (r *fakeRenderer) location() string {
+	return r.srv.URL + "/description.xml"
+}
+
+// received returns the received SOAP actions
+func (r *fakeRenderer) received() []string {
+	r.Lock()
+	defer r.Unlock()
+
+	return append([]string(nil), r.actions...)
+}
+
+// describe serves Sonos like device description with MediaRenderer sub-device
+func (r *fakeRenderer) describe(w http.ResponseWriter, req *http.Request) {
+	fmt.Fprintf(w, `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <friendlyName>%s</friendlyName>
+    <deviceList>
+      <device>
+        <friendlyName>%s - Media Renderer</friendlyName>
+        <serviceList>
+          <service>
+            <serviceType>%s</serviceType>
+            <controlURL>/MediaRenderer/AVTransport/Control</controlURL>
+          </service>
+          <service>
+            <serviceType>%s</serviceType>
+            <controlURL>/MediaRenderer/RenderingControl/Control</controlURL>
+          </service>
+        </serviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`, r.name, r.name, AVTransport, RenderingControl)
+}
+
+// control serves SOAP control requests
+func (r *fakeRenderer) control(w http.ResponseWriter, req *http.Request) {
+	soapAction := strings.Trim(req.Header.Get("SOAPACTION"), `"`)
+	action := soapAction[strings.Index(soapAction, "#")+1:]
+
+	args, err := soapArgs(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.Lock()
+	r.actions = append(r.actions, fmt.Sprintf("%s(%s)", action, strings.Join(args, ",")))
+	r.Unlock()
+
+	if action == r.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault>`+
+			`<faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail>`+
+			`<UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>701</errorCode>`+
+			`<errorDescription>Transition not available</errorDescription></UPnPError>`+
+			`</detail></s:Fault></s:Body></s:Envelope>`)
+		return
+	}
+
+	fmt.Fprintf(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+
+		`<u:%sResponse xmlns:u="%s">`, action, soapAction[:strings.Index(soapAction, "#")])
+	if action == "GetVolume" {
+		fmt.Fprintf(w, "<CurrentVolume>%d</CurrentVolume>", r.volume)
+	}
+	fmt.Fprintf(w, `</u:%sResponse></s:Body></s:Envelope>`, action)
+}
+
+// soapArgs returns SOAP action arguments as name=value strings
+func soapArgs(body io.Reader) ([]string, error) {
+	var args []string
+
+	d := xml.NewDecoder(body)
+	depth, name, value := 0, "", ""
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return args, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+			name, value = tok.Name.Local, ""
+		case xml.CharData:
+			value += string(tok)
+		case xml.EndElement:
+			// Envelope > Body > Action > Argument
+			if depth == 4 {
+				args = append(args, name+"="+value)
+			}
+			depth--
+		}
+	}
+}
+
+// newFakeSSDP starts fake SSDP responder which answers M-SEARCH requests with locations
+func newFakeSSDP(t *testing.T, locations ...string) string {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+
+			req := string(buf[:n])
+			if !strings.HasPrefix(req, "M-SEARCH * HTTP/1.1\r\n") || ssdpHeader(req, "ST") != AVTransport {
+				continue
+			}
+
+			for _, location := range locations {
+				resp := "HTTP/1.1 200 OK\r\n" +
+					"CACHE-CONTROL: max-age=1800\r\n" +
+					"LOCATION: " + location + "\r\n" +
+					"ST: " + AVTransport + "\r\n\r\n"
+				if _, err := conn.WriteToUDP([]byte(resp), addr); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestUPnPPlayerPlay(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		volume  int
+		fail    string
+		song    *alertify.Song
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "default stream",
+			stream: "http://alerts.example.com/default.mp3",
+			want: []string{
+				"SetAVTransportURI(InstanceID=0,CurrentURI=http://alerts.example.com/default.mp3,CurrentURIMetaData=)",
+				"Play(InstanceID=0,Speed=1)",
+			},
+		},
+		{
+			name:   "Spotify URL plays default stream",
+			stream: "http://alerts.example.com/default.mp3",
+			song:   &alertify.Song{URI: "https://open.spotify.com/track/7yTIKQzqRQfXDKKiPw3GJY"},
+			want: []string{
+				"SetAVTransportURI(InstanceID=0,CurrentURI=http://alerts.example.com/default.mp3,CurrentURIMetaData=)",
+				"Play(InstanceID=0,Speed=1)",
+			},
+		},
+		{
+			name: "song stream",
+			song: &alertify.Song{URI: "https://alerts.example.com/siren.mp3?loud=1&long=1"},
+			want: []string{
+				"SetAVTransportURI(InstanceID=0,CurrentURI=https://alerts.example.com/siren.mp3?loud=1&long=1,CurrentURIMetaData=)",
+				"Play(InstanceID=0,Speed=1)",
+			},
+		},
+		{
+			name:   "volume",
+			stream: "http://alerts.example.com/default.mp3",
+			song:   &alertify.Song{Volume: 40},
+			want: []string{
+				"SetVolume(InstanceID=0,Channel=Master,DesiredVolume=40)",
+				"SetAVTransportURI(InstanceID=0,CurrentURI=http://alerts.example.com/default.mp3,CurrentURIMetaData=)",
+				"Play(InstanceID=0,Speed=1)",
+			},
+		},
+		{
+			name:   "relative volume",
+			stream: "http://alerts.example.com/default.mp3",
+			volume: 30,
+			song:   &alertify.Song{Volume: 20, RelativeVolume: true},
+			want: []string{
+				"GetVolume(InstanceID=0,Channel=Master)",
+				"SetVolume(InstanceID=0,Channel=Master,DesiredVolume=50)",
+				"SetAVTransportURI(InstanceID=0,CurrentURI=http://alerts.example.com/default.mp3,CurrentURIMetaData=)",
+				"Play(InstanceID=0,Speed=1)",
+			},
+		},
+		{
+			name:   "relative volume clamped",
+			stream: "http://alerts.example.com/default.mp3",
+			volume: 90,
+			song:   &alertify.Song{Volume: 20, RelativeVolume: true},
+			want: []string{
+				"GetVolume(InstanceID=0,Channel=Master)",
+				"SetVolume(InstanceID=0,Channel=Master,DesiredVolume=100)",
+				"SetAVTransportURI(InstanceID=0,CurrentURI=http://alerts.example.com/default.mp3,CurrentURIMetaData=)",
+				"Play(InstanceID=0,Speed=1)",
+			},
+		},
+		{
+			name:   "volume failure still plays",
+			stream: "http://alerts.example.com/default.mp3",
+			fail:   "GetVolume",
+			song:   &alertify.Song{Volume: 20, RelativeVolume: true},
+			want: []string{
+				"GetVolume(InstanceID=0,Channel=Master)",
+				"SetAVTransportURI(InstanceID=0,CurrentURI=http://alerts.example.com/default.mp3,CurrentURIMetaData=)",
+				"Play(InstanceID=0,Speed=1)",
+			},
+		},
+		{
+			name:   "play failure",
+			stream: "http://alerts.example.com/default.mp3",
+			fail:   "Play",
+			want: []string{
+				"SetAVTransportURI(InstanceID=0,CurrentURI=http://alerts.example.com/default.mp3,CurrentURIMetaData=)",
+				"Play(InstanceID=0,Speed=1)",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no stream",
+			song:    &alertify.Song{URI: "alerts/siren.mp3"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newFakeRenderer(t, "Kitchen", tt.volume, tt.fail)
+
+			p, err := NewUPnPPlayer(&UPnPConfig{
+				Location:  r.location(),
+				StreamURL: tt.stream,
+				Timeout:   time.Second,
+			})
+			if err != nil {
+				t.Fatalf("failed to create UPnP player: %v", err)
+			}
+
+			err = p.Play(tt.song)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Play() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := r.received(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("actions = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUPnPPlayerPlayError(t *testing.T) {
+	r := newFakeRenderer(t, "Kitchen", 0, "Play")
+
+	p, err := NewUPnPPlayer(&UPnPConfig{
+		Location:  r.location(),
+		StreamURL: "http://alerts.example.com/default.mp3",
+		Timeout:   time.Second,
+	})
+	if err != nil {
+		t.Fatalf("failed to create UPnP player: %v", err)
+	}
+
+	err = p.Play(nil)
+	if err == nil || !strings.Contains(err.Error(), "error 701: Transition not available") {
+		t.Errorf("Play() error = %v, want UPnP error 701", err)
+	}
+}
+
+func TestUPnPPlayerDiscover(t *testing.T) {
+	kitchen := newFakeRenderer(t, "Kitchen", 0, "")
+	office := newFakeRenderer(t, "Office", 0, "")
+
+	tests := []struct {
+		name      string
+		renderer  string
+		locations []string
+		want      *fakeRenderer
+		wantErr   bool
+	}{
+		{
+			name:      "first renderer",
+			locations: []string{kitchen.location(), office.location()},
+			want:      kitchen,
+		},
+		{
+			name:      "renderer by name",
+			renderer:  "Office",
+			locations: []string{kitchen.location(), office.location()},
+			want:      office,
+		},
+		{
+			name:      "skip unavailable renderer",
+			renderer:  "Office",
+			locations: []string{kitchen.srv.URL + "/missing.xml", office.location()},
+			want:      office,
+		},
+		{
+			name:      "unknown renderer",
+			renderer:  "Garage",
+			locations: []string{kitchen.location(), office.location()},
+			wantErr:   true,
+		},
+		{
+			name:    "no renderer",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewUPnPPlayer(&UPnPConfig{
+				Name:     tt.renderer,
+				SSDPAddr: newFakeSSDP(t, tt.locations...),
+				Timeout:  200 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatalf("failed to create UPnP player: %v", err)
+			}
+
+			p.Lock()
+			r, err := p.discover()
+			p.Unlock()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("discover() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if want := tt.want.srv.URL + "/MediaRenderer/AVTransport/Control"; r.avTransport != want {
+				t.Errorf("AVTransport control URL = %s, want %s", r.avTransport, want)
+			}
+			if want := tt.want.srv.URL + "/MediaRenderer/RenderingControl/Control"; r.renderingControl != want {
+				t.Errorf("RenderingControl control URL = %s, want %s", r.renderingControl, want)
+			}
+			if r.name != tt.want.name {
+				t.Errorf("renderer name = %s, want %s", r.name, tt.want.name)
+			}
+		})
+	}
+}
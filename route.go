@@ -0,0 +1,112 @@
+package alertify
+
+// Alert is an alert notification sent to Bot in alert Msg
+type Alert struct {
+	// Labels describe the alert and are used to select alert Route
+	Labels map[string]string
+	// Song overrides the song selected by the alert Route
+	Song *Song
+}
+
+// Route configures alert song playback for alerts whose labels match the route
+type Route struct {
+	// Name is the route name
+	Name string
+	// Match contains label values the alert must have to match the route.
+	// Route with empty Match matches all alerts.
+	Match map[string]string
+	// Song overrides the default alert song for the matching alerts
+	Song *Song
+}
+
+// Matches returns true if labels match the route
+func (r *Route) Matches(labels map[string]string) bool {
+	for k, v := range r.Match {
+		if labels[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// route returns the first route matching labels or nil if there is no such route
+func (b *Bot) route(labels map[string]string) *Route {
+	for _, r := range b.routes {
+		if r.Matches(labels) {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// alertSong returns the song which should be played for alert
+// The default song is overridden by the matching route song and then by the alert song.
+func (b *Bot) alertSong(a *Alert) *Song {
+	song := mergeSong(b.song, nil)
+	if r := b.route(a.Labels); r != nil {
+		song = mergeSong(song, r.Song)
+	}
+
+	return mergeSong(song, a.Song)
+}
+
+// mergeSong returns a copy of song with fields overridden by non-zero fields of o
+func mergeSong(song, o *Song) *Song {
+	s := *song
+	if o == nil {
+		return &s
+	}
+
+	if o.URI != "" {
+		s.URI = o.URI
+	}
+	if o.Shuffle {
+		s.Shuffle = true
+	}
+	if o.Offset != 0 {
+		s.Offset = o.Offset
+	}
+	if o.Random {
+		s.Random = true
+	}
+	if o.Volume != 0 {
+		s.Volume = o.Volume
+		s.RelativeVolume = o.RelativeVolume
+	}
+	if o.VolumeRamp != 0 {
+		s.VolumeRamp = o.VolumeRamp
+	}
+	if o.PositionMs != 0 {
+		s.PositionMs = o.PositionMs
+	}
+	if o.Player != "" {
+		s.Player = o.Player
+	}
+	if o.FallbackPlayer != "" {
+		s.FallbackPlayer = o.FallbackPlayer
+	}
+	if o.Account != "" {
+		s.Account = o.Account
+	}
+	if o.Device != "" {
+		s.Device = o.Device
+		s.DeviceGroup = ""
+	}
+	if o.DeviceGroup != "" {
+		s.DeviceGroup = o.DeviceGroup
+		s.Device = ""
+	}
+	if o.MaxDuration != 0 {
+		s.MaxDuration = o.MaxDuration
+	}
+	if o.Announcement != "" {
+		s.Announcement = o.Announcement
+	}
+	if o.AnnounceOnly {
+		s.AnnounceOnly = true
+	}
+
+	return &s
+}
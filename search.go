@@ -0,0 +1,66 @@
+package alertify
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/zmb3/spotify"
+)
+
+// IsSearchQuery returns true if song is a Spotify search expression rather than Spotify URI or URL
+// Search expressions use Spotify search syntax, e.g. artist:"John Denver" track:"Country Roads"
+func IsSearchQuery(song string) bool {
+	switch {
+	case song == "":
+		return false
+	case strings.HasPrefix(song, "spotify:"):
+		return false
+	case strings.HasPrefix(song, "http://"), strings.HasPrefix(song, "https://"):
+		return false
+	}
+
+	return true
+}
+
+// ResolveSong returns Spotify URI of song
+// If song is a search expression it's resolved to the URI of the first matching track
+// via Spotify search API. Resolved URIs are cached, so every search expression is only
+// resolved once. It returns error if no track matches the search expression.
+func (s *SpotifyClient) ResolveSong(song string) (string, error) {
+	if !IsSearchQuery(song) {
+		return song, nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	defer s.persistToken()
+
+	if uri, ok := s.songs[song]; ok {
+		return uri, nil
+	}
+
+	limit := 1
+	market := spotify.MarketFromToken
+	opts := &spotify.Options{
+		Limit:   &limit,
+		Country: &market,
+	}
+
+	result, err := s.SearchOpt(song, spotify.SearchTypeTrack, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to search for %s: %s", song, err)
+	}
+
+	if result.Tracks == nil || len(result.Tracks.Tracks) == 0 {
+		return "", fmt.Errorf("no Spotify track matches %s", song)
+	}
+
+	track := result.Tracks.Tracks[0]
+	uri := string(track.URI)
+	log.Printf("Resolved %s to \"%s\": %s", song, track.Name, uri)
+
+	s.songs[song] = uri
+
+	return uri, nil
+}
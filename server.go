@@ -18,7 +18,10 @@ type API struct {
 
 // Context provides API service context
 type Context struct {
+	// msgChan sends command messages to Bot
 	msgChan chan *Msg
+	// closeChan is closed when Bot message listener stops
+	closeChan chan struct{}
 }
 
 // newListener creates a new TCP listener
@@ -0,0 +1,146 @@
+package alertify
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+const (
+	// DefaultSongURI is Spotify URI of the song played when no song is configured
+	DefaultSongURI = "spotify:track:7yTIKQzqRQfXDKKiPw3GJY"
+)
+
+// Song configures alert song playback
+type Song struct {
+	// URI is Spotify URI or open.spotify.com URL of a track, album, playlist or artist.
+	// It can also be a Spotify search expression which is resolved to the first matching track.
+	URI string
+	// Shuffle enables shuffle when playing album, playlist or artist
+	Shuffle bool
+	// Offset is zero based position of album or playlist track to start playing from
+	Offset int
+	// Random plays a randomly selected album or playlist track
+	Random bool
+	// Volume is the alert volume in percent.
+	// Zero value leaves the device volume unchanged.
+	Volume int
+	// RelativeVolume adds Volume to the current device volume instead of setting it
+	RelativeVolume bool
+	// VolumeRamp ramps the volume up to Volume over the given duration
+	VolumeRamp time.Duration
+	// PositionMs is the position in milliseconds to start playing the track from
+	PositionMs int
+	// Player is the name of the player to play the song with.
+	// Empty Player plays the song on Spotify.
+	Player string
+	// FallbackPlayer is the name of the player to play the song with when the song fails to play
+	FallbackPlayer string
+	// Account is the name of Spotify account to play the song with.
+	// Empty Account plays the song with the default Spotify account.
+	Account string
+	// Device is the name or ID of Spotify device to play the song on
+	Device string
+	// DeviceGroup is the name of Spotify device group to play the song on.
+	// Empty Device and DeviceGroup play the song on the configured account device.
+	DeviceGroup string
+	// MaxDuration is the maximum alert play duration after which the alert is silenced.
+	// Zero value plays the alert until it's silenced or finishes playing.
+	MaxDuration time.Duration
+	// Announcement is the text announced before the song is played.
+	// It's a text/template rendered with alert labels, e.g. "{{.alertname}} is firing".
+	Announcement string
+	// AnnounceOnly plays the announcement instead of the song.
+	// The song is still played if the announcement fails.
+	AnnounceOnly bool
+}
+
+// SpotifyURI is parsed Spotify URI
+type SpotifyURI struct {
+	// Type is Spotify item type: track, album, playlist or artist
+	Type string
+	// ID is Spotify item ID
+	ID spotify.ID
+	// Owner is the playlist owner if it's present in the URI
+	Owner string
+}
+
+// IsSpotifyURI returns true if uri is Spotify URI or open.spotify.com URL
+// Unlike ParseSpotifyURI, it doesn't check the URI refers to a playable Spotify item.
+func IsSpotifyURI(uri string) bool {
+	if strings.HasPrefix(uri, "spotify:") {
+		return true
+	}
+
+	u, err := url.Parse(uri)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host == "open.spotify.com"
+}
+
+// ParseSpotifyURI parses Spotify URI or open.spotify.com URL and returns it
+// It accepts the following formats:
+// spotify:track:ID, spotify:user:USER:playlist:ID, https://open.spotify.com/album/ID
+// It returns error if uri is not a track, album, playlist or artist URI.
+func ParseSpotifyURI(uri string) (*SpotifyURI, error) {
+	var parts []string
+
+	switch {
+	case strings.HasPrefix(uri, "spotify:"):
+		parts = strings.Split(strings.TrimPrefix(uri, "spotify:"), ":")
+	case strings.HasPrefix(uri, "http://"), strings.HasPrefix(uri, "https://"):
+		u, err := url.Parse(uri)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Spotify URL %s: %s", uri, err)
+		}
+		if u.Host != "open.spotify.com" {
+			return nil, fmt.Errorf("invalid Spotify URL host: %s", u.Host)
+		}
+		parts = strings.Split(strings.Trim(u.Path, "/"), "/")
+		// skip localized URL prefix e.g. /intl-de/track/ID
+		if len(parts) > 0 && strings.HasPrefix(parts[0], "intl-") {
+			parts = parts[1:]
+		}
+	default:
+		return nil, fmt.Errorf("invalid Spotify URI: %s", uri)
+	}
+
+	var owner string
+	if len(parts) == 4 && parts[0] == "user" {
+		owner = parts[1]
+		parts = parts[2:]
+	}
+
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid Spotify URI: %s", uri)
+	}
+
+	switch parts[0] {
+	case "track", "album", "playlist", "artist":
+	default:
+		return nil, fmt.Errorf("unsupported Spotify URI type: %s", parts[0])
+	}
+
+	return &SpotifyURI{
+		Type:  parts[0],
+		ID:    spotify.ID(parts[1]),
+		Owner: owner,
+	}, nil
+}
+
+// URI returns canonical Spotify URI
+func (u *SpotifyURI) URI() spotify.URI {
+	return spotify.URI("spotify:" + u.Type + ":" + string(u.ID))
+}
+
+// IsContext returns true if the URI is a playback context i.e. album, playlist or artist
+func (u *SpotifyURI) IsContext() bool {
+	return u.Type != "track"
+}
+
+// String implements stringer interface
+func (u *SpotifyURI) String() string {
+	return string(u.URI())
+}
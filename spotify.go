@@ -1,14 +1,32 @@
 package alertify
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"net"
 	"net/http"
-	"strings"
+	"os"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/zmb3/spotify"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// DefaultSpotifyRetries is default number of Spotify API request attempts
+	DefaultSpotifyRetries = 3
+	// DefaultSpotifyMinBackoff is default initial delay between Spotify API request attempts
+	DefaultSpotifyMinBackoff = 500 * time.Millisecond
+	// DefaultSpotifyMaxBackoff is default maximum delay between Spotify API request attempts
+	DefaultSpotifyMaxBackoff = 1 * time.Second
+	// spotifyAPIURL is Spotify Web API URL
+	spotifyAPIURL = "https://api.spotify.com/v1/"
+	// spotifyAPITimeout is Spotify Web API request timeout
+	spotifyAPITimeout = 10 * time.Second
 )
 
 // SpotifyConfig configures Spotify API client
@@ -19,12 +37,51 @@ type SpotifyConfig struct {
 	ClientSecret string
 	// RedirectURI is Spotify app OAuth redirect URI
 	RedirectURI string
+	// TokenFile is path to file which stores Spotify OAuth token.
+	// Stored token is reused on restart so you don't need to log in to Spotify again.
+	TokenFile string
 	// DeviceID is Spotify device ID
 	DeviceID string
 	// DeviceName is Spotify device name
 	DeviceName string
-	// SongURI is Spotify song URI
+	// FallbackDeviceID is ID of Spotify device used when the primary device is unavailable
+	FallbackDeviceID string
+	// FallbackDeviceName is name of Spotify device used when the primary device is unavailable
+	FallbackDeviceName string
+	// DeviceGroups are named groups of Spotify devices alerts can be played on
+	DeviceGroups map[string]*DeviceGroup
+	// SongURI is Spotify URI or open.spotify.com URL of a track, album, playlist or artist.
+	// It can also be a Spotify search expression, e.g. artist:"John Denver" track:"Country Roads"
 	SongURI string
+	// Shuffle enables shuffle when SongURI is album, playlist or artist
+	Shuffle bool
+	// Offset is zero based position of album or playlist track to start playing from
+	Offset int
+	// RandomTrack plays a randomly selected album or playlist track
+	RandomTrack bool
+	// Volume is the alert volume in percent; zero value leaves device volume unchanged
+	Volume int
+	// RelativeVolume adds Volume to the current device volume instead of setting it
+	RelativeVolume bool
+	// VolumeRamp ramps the alert volume up to Volume over the given duration
+	VolumeRamp time.Duration
+	// PositionMs is the position in milliseconds to start playing the alert track from
+	PositionMs int
+	// MaxDuration is the maximum alert play duration after which the alert is silenced
+	MaxDuration time.Duration
+	// RestorePlayback restores the playback interrupted by the alert once the alert is silenced or finishes
+	RestorePlayback bool
+	// Retries is the maximum number of Spotify API request attempts.
+	// Zero value means DefaultSpotifyRetries.
+	Retries int
+	// RetryMinBackoff is the initial delay between Spotify API request attempts
+	RetryMinBackoff time.Duration
+	// RetryMaxBackoff is the maximum delay between Spotify API request attempts
+	RetryMaxBackoff time.Duration
+	// RetryMaxDelay is the maximum total delay between Spotify API request attempts, including
+	// the delays rate limited requests wait for as asked by Retry-After header. Request is not
+	// retried once the total delay would exceed it. It defaults to RetryMaxBackoff for every retry.
+	RetryMaxDelay time.Duration
 }
 
 // SpotifyAuth allows to authenticate with Spotify API
@@ -61,6 +118,36 @@ type SpotifyClient struct {
 	*spotify.Client
 	// device is Spotify player device
 	device *spotify.PlayerDevice
+	// deviceID is configured Spotify device ID
+	deviceID string
+	// deviceName is configured Spotify device name
+	deviceName string
+	// fallbackID is configured fallback Spotify device ID
+	fallbackID string
+	// fallbackName is configured fallback Spotify device name
+	fallbackName string
+	// groups are named groups of Spotify devices
+	groups map[string]*DeviceGroup
+	// tokenFile is path to file which stores OAuth token
+	tokenFile string
+	// token is the last stored OAuth token
+	token *oauth2.Token
+	// songs caches song URIs resolved from search expressions
+	songs map[string]string
+	// retries is the maximum number of API request attempts
+	retries int
+	// minBackoff is the initial delay between API request attempts
+	minBackoff time.Duration
+	// maxBackoff is the maximum delay between API request attempts
+	maxBackoff time.Duration
+	// maxDelay is the maximum total delay between API request attempts
+	maxDelay time.Duration
+	// apiURL is Spotify Web API URL
+	apiURL string
+	// http sends Spotify Web API requests the Spotify client can't send
+	http *http.Client
+	// rand picks random tracks; it must be used with client lock held
+	rand *rand.Rand
 	// mutex
 	*sync.Mutex
 }
@@ -86,9 +173,9 @@ func authHandler(auth *SpotifyAuth, ch chan *spotify.Client) http.Handler {
 	})
 }
 
-// NewSpotifyClient authenticates with Spotify API and returns SpotifyClient
-// It returns error if Spotify API authentication fails
-func NewSpotifyClient(c *SpotifyConfig) (*SpotifyClient, error) {
+// authorize runs Spotify OAuth flow and returns authenticated Spotify client
+// It asks the user to log in to Spotify and waits for the OAuth redirect callback.
+func authorize(auth *SpotifyAuth) (*spotify.Client, error) {
 	clientChan := make(chan *spotify.Client)
 	errChan := make(chan error, 1)
 	// create OAuth listener for RedirectURI callback
@@ -96,8 +183,6 @@ func NewSpotifyClient(c *SpotifyConfig) (*SpotifyClient, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create TCP listener: %s", err)
 	}
-	// Spotify authenticator
-	auth := NewSpotifyAuth(c.ClientID, c.ClientSecret, c.RedirectURI, "abc123")
 	// Create HTTP muxer
 	h := http.NewServeMux()
 	h.Handle("/callback", authHandler(auth, clientChan))
@@ -130,15 +215,112 @@ func NewSpotifyClient(c *SpotifyConfig) (*SpotifyClient, error) {
 		return nil, fmt.Errorf("failed to create Spotify client: %s", err)
 	}
 
+	return client, nil
+}
+
+// NewSpotifyClient authenticates with Spotify API and returns SpotifyClient
+// If TokenFile is configured and it contains OAuth token, the token is used instead of
+// asking the user to log in to Spotify. The token is stored in TokenFile once obtained.
+// If the stored token is no longer valid, the user is asked to log in again.
+// It returns error if Spotify API authentication fails
+func NewSpotifyClient(c *SpotifyConfig) (*SpotifyClient, error) {
+	if err := validateGroups(c.DeviceGroups); err != nil {
+		return nil, err
+	}
+
+	// Spotify authenticator
+	auth := NewSpotifyAuth(c.ClientID, c.ClientSecret, c.RedirectURI, "abc123")
+
+	var (
+		client *spotify.Client
+		token  *oauth2.Token
+		err    error
+	)
+
+	if c.TokenFile != "" {
+		token, err = loadToken(c.TokenFile)
+		switch {
+		case err == nil:
+			_client := auth.NewClient(token)
+			client = &_client
+		case os.IsNotExist(err):
+		default:
+			log.Printf("Failed to read Spotify OAuth token from %s: %s", c.TokenFile, err)
+		}
+	}
+
+	if client == nil {
+		if client, err = authorize(auth); err != nil {
+			return nil, err
+		}
+		// token is stored once the client is created
+		token = nil
+	}
+
+	retries := c.Retries
+	if retries <= 0 {
+		retries = DefaultSpotifyRetries
+	}
+	minBackoff := c.RetryMinBackoff
+	if minBackoff <= 0 {
+		minBackoff = DefaultSpotifyMinBackoff
+	}
+	maxBackoff := c.RetryMaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultSpotifyMaxBackoff
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = minBackoff
+	}
+	maxDelay := c.RetryMaxDelay
+	if maxDelay <= 0 {
+		maxDelay = time.Duration(retries-1) * maxBackoff
+	}
+
 	// configure Spotify player device
 	deviceID := spotify.ID(c.DeviceID)
 	device := &spotify.PlayerDevice{ID: deviceID}
-	_client := &SpotifyClient{client, device, &sync.Mutex{}}
-	if err := _client.SetDevice(c.DeviceID, c.DeviceName); err != nil {
+	_client := &SpotifyClient{
+		Client:       client,
+		device:       device,
+		deviceID:     c.DeviceID,
+		deviceName:   c.DeviceName,
+		fallbackID:   c.FallbackDeviceID,
+		fallbackName: c.FallbackDeviceName,
+		groups:       c.DeviceGroups,
+		tokenFile:    c.TokenFile,
+		token:        token,
+		songs:        make(map[string]string),
+		retries:      retries,
+		minBackoff:   minBackoff,
+		maxBackoff:   maxBackoff,
+		maxDelay:     maxDelay,
+		apiURL:       spotifyAPIURL,
+		http:         &http.Client{Timeout: spotifyAPITimeout},
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+		Mutex:        &sync.Mutex{},
+	}
+
+	err = _client.resolveDevice()
+	if err != nil && token != nil && isAuthError(err) {
+		// stored token has been revoked or it can't be refreshed anymore
+		log.Printf("Stored Spotify OAuth token is no longer valid: %s", err)
+		if client, err = authorize(auth); err != nil {
+			return nil, err
+		}
+		_client.Client = client
+		_client.token = nil
+		err = _client.resolveDevice()
+	}
+	if err != nil {
 		return nil, err
 	}
 
-	return _client, err
+	_client.Lock()
+	_client.persistToken()
+	_client.Unlock()
+
+	return _client, nil
 }
 
 // Device returns Spotify active playback device
@@ -153,83 +335,469 @@ func (s *SpotifyClient) SetDevice(deviceID, deviceName string) error {
 	// prevent multiple client device modifications
 	s.Lock()
 	defer s.Unlock()
+	defer s.persistToken()
+
+	s.deviceID = deviceID
+	s.deviceName = deviceName
+
+	return s.setDevice()
+}
+
+// resolveDevice looks up configured Spotify player device
+func (s *SpotifyClient) resolveDevice() error {
+	s.Lock()
+	defer s.Unlock()
 
+	return s.setDevice()
+}
+
+// setDevice looks up configured Spotify player device and sets it as playback device
+// If the configured device is not available it falls back to fallback device and then
+// to the first active device it finds. It must be called with client lock held.
+func (s *SpotifyClient) setDevice() error {
 	// get all available Spotify player devices
 	devices, err := s.PlayerDevices()
 	if err != nil {
 		return err
 	}
 
-	var activeDevices []spotify.PlayerDevice
-	// loop over available devices
-	for _, device := range devices {
-		if !device.Restricted {
-			// Search by device ID
-			if deviceID == device.ID.String() {
-				s.device.ID = device.ID
-				s.device.Name = device.Name
-				return nil
-			}
-			// search by device Name
-			if device.Name == deviceName {
-				s.device.ID = device.ID
-				s.device.Name = device.Name
-				return nil
+	device := findDevice(devices, s.deviceID, s.deviceName)
+	if device == nil && (s.fallbackID != "" || s.fallbackName != "") {
+		if device = findDevice(devices, s.fallbackID, s.fallbackName); device != nil {
+			log.Printf("Spotify device unavailable, falling back to Device ID: %s Name: %s", device.ID, device.Name)
+		}
+	}
+	if device == nil {
+		for i := range devices {
+			if !devices[i].Restricted {
+				device = &devices[i]
+				break
 			}
-			activeDevices = append(activeDevices, device)
 		}
 	}
 
-	if len(activeDevices) != 0 {
-		s.device.ID = activeDevices[0].ID
-		s.device.Name = activeDevices[0].Name
-		return nil
+	if device == nil {
+		return fmt.Errorf("no active Spotify devices found")
 	}
 
-	return fmt.Errorf("no active Spotify devices found")
+	s.device.ID = device.ID
+	s.device.Name = device.Name
+
+	return nil
+}
+
+// findDevice searches for unrestricted device by its ID or name in devices
+func findDevice(devices []spotify.PlayerDevice, deviceID, deviceName string) *spotify.PlayerDevice {
+	for i, device := range devices {
+		if device.Restricted {
+			continue
+		}
+		// Search by device ID
+		if deviceID != "" && deviceID == device.ID.String() {
+			return &devices[i]
+		}
+		// search by device Name
+		if deviceName != "" && device.Name == deviceName {
+			return &devices[i]
+		}
+	}
+
+	return nil
+}
+
+// deviceListed checks if the playback device is still listed by Spotify API
+// It must be called with client lock held.
+func (s *SpotifyClient) deviceListed(device *spotify.PlayerDevice) (bool, error) {
+	devices, err := s.PlayerDevices()
+	if err != nil {
+		return false, err
+	}
+
+	for _, d := range devices {
+		if d.ID == device.ID && !d.Restricted {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// isRetryable returns true if failed Spotify API request should be retried
+// Only network errors, rate limited requests and server errors are retried.
+func isRetryable(err error) bool {
+	var apiErr spotify.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Status == http.StatusTooManyRequests || apiErr.Status >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// isRateLimited returns true if Spotify API request failed because the client has been rate limited
+func isRateLimited(err error) bool {
+	var apiErr spotify.Error
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusTooManyRequests
+}
+
+// retryAfter returns how long Spotify API asks the rate limited client to wait before sending requests again
+// Spotify client doesn't expose response headers, so the delay is read from the response of playback
+// devices request. It returns false if the client is no longer rate limited or if the delay is unknown.
+// It must be called with client lock held.
+func (s *SpotifyClient) retryAfter() (time.Duration, bool) {
+	tok, err := s.Token()
+	if err != nil {
+		log.Printf("Failed to read Spotify OAuth token: %s", err)
+		return 0, false
+	}
+
+	req, err := http.NewRequest(http.MethodGet, s.apiURL+"me/player/devices", nil)
+	if err != nil {
+		return 0, false
+	}
+	tok.SetAuthHeader(req)
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		log.Printf("Failed to read Spotify API rate limit: %s", err)
+		return 0, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	return parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+}
+
+// parseRetryAfter parses Retry-After header value given either in seconds or as HTTP date
+// It returns false if the value is invalid.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+
+	return 0, true
+}
+
+// isAuthError returns true if Spotify API request failed because OAuth token has been revoked
+// or because it could not be refreshed
+func isAuthError(err error) bool {
+	var apiErr spotify.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Status == http.StatusUnauthorized
+	}
+
+	var retrieveErr *oauth2.RetrieveError
+	return errors.As(err, &retrieveErr)
+}
+
+// isNotFound returns true if Spotify API request failed because the playback device was not found
+func isNotFound(err error) bool {
+	var apiErr spotify.Error
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+// retry calls op on the playback device until it succeeds or until it runs out of attempts
+// It must be called with client lock held.
+func (s *SpotifyClient) retry(op func() error) error {
+	return s.retryOn(s.device, s.setDevice, op)
+}
+
+// retryOn calls op on device until it succeeds or until it runs out of attempts
+// When op fails the device is looked up again using resolve if it's no longer listed by Spotify API.
+// Requests which failed because the device was not found are only retried if the device has been
+// looked up again. The client lock is released while waiting for the next attempt.
+// It must be called with client lock held.
+func (s *SpotifyClient) retryOn(device *spotify.PlayerDevice, resolve func() error, op func() error) error {
+	bo := newBackoff(s.minBackoff, s.maxBackoff)
+
+	var waited time.Duration
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil {
+			return nil
+		}
+
+		notFound := isNotFound(err)
+		if attempt >= s.retries || (!notFound && !isRetryable(err)) {
+			return err
+		}
+
+		delay := bo.Next()
+		resolved := false
+		if isRateLimited(err) {
+			// rate limited client must wait as long as Spotify API asks it to
+			if after, ok := s.retryAfter(); ok {
+				delay = after
+			}
+		} else {
+			listed, lErr := s.deviceListed(device)
+			switch {
+			case lErr != nil:
+				log.Printf("Failed to list Spotify devices: %s", lErr)
+			case !listed:
+				log.Printf("Spotify device ID: %s Name: %s is no longer available", device.ID, device.Name)
+				if dErr := resolve(); dErr != nil {
+					log.Printf("Failed to find Spotify device: %s", dErr)
+				} else {
+					resolved = true
+				}
+			}
+		}
+
+		if notFound && !resolved {
+			return err
+		}
+
+		if waited+delay > s.maxDelay {
+			log.Printf("Spotify API request failed: %s, not retrying as it would wait for %s", err, waited+delay)
+			return err
+		}
+		waited += delay
+
+		log.Printf("Spotify API request failed: %s, retrying in %s", err, delay)
+		s.Unlock()
+		time.Sleep(delay)
+		s.Lock()
+	}
 }
 
 // PlaySong plays Spotify song passed in as songURI
 func (s *SpotifyClient) PlaySong(songURI string) error {
+	return s.Play(&Song{URI: songURI})
+}
+
+// Play plays Spotify song
+// Song URI can point to a track, album, playlist or artist. Albums, playlists and artists
+// are played as playback context with optional shuffle and start offset.
+func (s *SpotifyClient) Play(song *Song) error {
 	s.Lock()
 	defer s.Unlock()
+	defer s.persistToken()
+
+	return s.play(song, s.device, s.setDevice)
+}
+
+// play plays Spotify song on device
+// If device becomes unavailable it's looked up again using resolve.
+// It must be called with client lock held.
+func (s *SpotifyClient) play(song *Song, device *spotify.PlayerDevice, resolve func() error) error {
 	// if empty, play default song
+	songURI := song.URI
 	if songURI == "" {
-		songURI = "spotify:track:7yTIKQzqRQfXDKKiPw3GJY"
+		songURI = DefaultSongURI
 	}
-	// set playback options
-	opts := &spotify.PlayOptions{
-		DeviceID: &s.device.ID,
-		URIs:     []spotify.URI{spotify.URI(songURI)},
+
+	uri, err := ParseSpotifyURI(songURI)
+	if err != nil {
+		return err
 	}
 
-	var trackName string
-	trackInfo := strings.Split(songURI, ":")
-	if len(trackInfo) != 3 {
-		log.Printf("Could not parse Spotify ID from %s", songURI)
+	// set playback options
+	opts := &spotify.PlayOptions{}
+	if uri.IsContext() {
+		contextURI := uri.URI()
+		opts.PlaybackContext = &contextURI
+		offset := song.Offset
+		if song.Random && uri.Type != "artist" {
+			if offset, err = s.randomOffset(uri); err != nil {
+				log.Printf("Failed to pick random track from %s: %s", uri, err)
+				offset = song.Offset
+			}
+		}
+		// Spotify API only supports offsets for albums and playlists
+		if offset > 0 && uri.Type != "artist" {
+			opts.PlaybackOffset = &spotify.PlaybackOffset{Position: offset}
+		}
 	} else {
-		track, err := s.GetTrack(spotify.ID(trackInfo[2]))
+		opts.URIs = []spotify.URI{uri.URI()}
+	}
+
+	name := s.itemName(uri)
+
+	err = s.retryOn(device, resolve, func() error {
+		opts.DeviceID = &device.ID
+
+		log.Printf("Attempting to play %s: \"%s\" on Device ID: %s Name: %s", uri.Type, name, device.ID, device.Name)
+
+		return s.PlayOpt(opts)
+	})
+	if err != nil {
+		return err
+	}
+
+	deviceOpts := &spotify.PlayOptions{DeviceID: &device.ID}
+	// Spotify API doesn't allow to start playback at a given position
+	if song.PositionMs > 0 {
+		if err := s.SeekOpt(song.PositionMs, deviceOpts); err != nil {
+			log.Printf("Failed to seek to %dms on Device ID: %s Name: %s: %s", song.PositionMs, device.ID, device.Name, err)
+		}
+	}
+
+	if !uri.IsContext() {
+		return nil
+	}
+
+	// shuffle state can only be changed once the device is playing
+	// artists don't support offsets so shuffle is the only way to randomize them
+	randomArtist := song.Random && uri.Type == "artist"
+	if err := s.ShuffleOpt(song.Shuffle || randomArtist, deviceOpts); err != nil {
+		log.Printf("Failed to set shuffle on Device ID: %s Name: %s: %s", device.ID, device.Name, err)
+		return nil
+	}
+	if randomArtist {
+		if err := s.NextOpt(deviceOpts); err != nil {
+			log.Printf("Failed to skip to random track of %s: %s", uri, err)
+		}
+	}
+
+	return nil
+}
+
+// itemName returns the name of Spotify item referenced by uri
+// It must be called with client lock held.
+func (s *SpotifyClient) itemName(uri *SpotifyURI) string {
+	var (
+		name string
+		err  error
+	)
+
+	switch uri.Type {
+	case "track":
+		var track *spotify.FullTrack
+		if track, err = s.GetTrack(uri.ID); err == nil {
+			name = track.Name
+		}
+	case "album":
+		var album *spotify.FullAlbum
+		if album, err = s.GetAlbum(uri.ID); err == nil {
+			name = album.Name
+		}
+	case "artist":
+		var artist *spotify.FullArtist
+		if artist, err = s.GetArtist(uri.ID); err == nil {
+			name = artist.Name
+		}
+	case "playlist":
+		var playlist *spotify.FullPlaylist
+		if playlist, err = s.playlist(uri, "name"); err == nil {
+			name = playlist.Name
+		}
+	}
+
+	if err != nil {
+		log.Printf("Failed to get %s %s name: %s", uri.Type, uri, err)
+		return "Unknown"
+	}
+
+	return name
+}
+
+// playlist fetches playlist fields
+// Playlists used to be addressed by their owner; if the URI doesn't contain one,
+// the current user is used as Spotify API does not check the owner anymore.
+// It must be called with client lock held.
+func (s *SpotifyClient) playlist(uri *SpotifyURI, fields string) (*spotify.FullPlaylist, error) {
+	owner := uri.Owner
+	if owner == "" {
+		user, err := s.CurrentUser()
 		if err != nil {
-			log.Printf("Failed to get %s track name: %s", songURI, err)
-			trackName = "Unknown"
-		} else {
-			trackName = track.Name
+			return nil, err
 		}
+		owner = user.ID
 	}
 
-	log.Printf("Attempting to play: \"%s\" on Device ID: %s Name: %s", trackName, s.device.ID, s.device.Name)
+	return s.GetPlaylistOpt(owner, uri.ID, fields)
+}
+
+// randomOffset returns a random track position in album or playlist
+// It must be called with client lock held.
+func (s *SpotifyClient) randomOffset(uri *SpotifyURI) (int, error) {
+	var total int
+
+	switch uri.Type {
+	case "album":
+		album, err := s.GetAlbum(uri.ID)
+		if err != nil {
+			return 0, err
+		}
+		total = album.Tracks.Total
+	case "playlist":
+		playlist, err := s.playlist(uri, "tracks.total")
+		if err != nil {
+			return 0, err
+		}
+		total = playlist.Tracks.Total
+	default:
+		return 0, fmt.Errorf("random track not supported for %s", uri.Type)
+	}
+
+	if total == 0 {
+		return 0, fmt.Errorf("%s %s is empty", uri.Type, uri)
+	}
 
-	return s.PlayOpt(opts)
+	return s.rand.Intn(total), nil
+}
+
+// DeviceVolume returns the volume of Spotify playback device in percent
+func (s *SpotifyClient) DeviceVolume() (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	defer s.persistToken()
+
+	devices, err := s.PlayerDevices()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, device := range devices {
+		if device.ID == s.device.ID {
+			return device.Volume, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Spotify device ID: %s Name: %s not found", s.device.ID, s.device.Name)
+}
+
+// SetVolume sets the volume of Spotify playback device to percent
+func (s *SpotifyClient) SetVolume(percent int) error {
+	s.Lock()
+	defer s.Unlock()
+	defer s.persistToken()
+
+	return s.retry(func() error {
+		return s.VolumeOpt(percent, &spotify.PlayOptions{DeviceID: &s.device.ID})
+	})
 }
 
 // Pause pauses active playback on a currently active Spotify device
 // It returns error if the playback can't be paused
 func (s *SpotifyClient) Pause() error {
-	opts := &spotify.PlayOptions{
-		DeviceID: &s.device.ID,
-	}
+	s.Lock()
+	defer s.Unlock()
+	defer s.persistToken()
 
-	log.Printf("Attempting to pause alert playback on Device ID: %s Name: %s", s.device.ID, s.device.Name)
+	return s.retry(func() error {
+		opts := &spotify.PlayOptions{
+			DeviceID: &s.device.ID,
+		}
+
+		log.Printf("Attempting to pause alert playback on Device ID: %s Name: %s", s.device.ID, s.device.Name)
 
-	return s.PauseOpt(opts)
+		return s.PauseOpt(opts)
+	})
 }
@@ -0,0 +1,167 @@
+package alertify
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify"
+	"golang.org/x/oauth2"
+)
+
+// newTestSpotifyClient returns Spotify client whose rate limit is read from fake Spotify API
+// which responds to playback devices requests with retryAfter header value
+func newTestSpotifyClient(t *testing.T, retryAfter string, retries int, maxDelay time.Duration) *SpotifyClient {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/me/player/devices" || r.Header.Get("Authorization") != "Bearer access-token" {
+			http.NotFound(w, r)
+			return
+		}
+		if retryAfter == "" {
+			fmt.Fprint(w, `{"devices":[]}`)
+			return
+		}
+		w.Header().Set("Retry-After", retryAfter)
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"status":429,"message":"API rate limit exceeded"}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := spotify.NewAuthenticator("").NewClient(&oauth2.Token{
+		AccessToken: "access-token",
+		TokenType:   "Bearer",
+		Expiry:      time.Now().Add(time.Hour),
+	})
+
+	return &SpotifyClient{
+		Client:     &client,
+		device:     &spotify.PlayerDevice{ID: "device"},
+		retries:    retries,
+		minBackoff: time.Millisecond,
+		maxBackoff: time.Millisecond,
+		maxDelay:   maxDelay,
+		apiURL:     srv.URL + "/v1/",
+		http:       srv.Client(),
+		Mutex:      &sync.Mutex{},
+	}
+}
+
+func TestSpotifyClientRetryRateLimited(t *testing.T) {
+	rateLimited := spotify.Error{Message: "API rate limit exceeded", Status: http.StatusTooManyRequests}
+	forbidden := spotify.Error{Message: "Player command failed: Restriction violated", Status: http.StatusForbidden}
+
+	tests := []struct {
+		name         string
+		retryAfter   string
+		retries      int
+		maxDelay     time.Duration
+		errs         []error
+		wantAttempts int
+		wantWait     time.Duration
+		wantErr      bool
+	}{
+		{
+			name:         "waits for Retry-After",
+			retryAfter:   "1",
+			retries:      3,
+			maxDelay:     2 * time.Second,
+			errs:         []error{rateLimited},
+			wantAttempts: 2,
+			wantWait:     time.Second,
+		},
+		{
+			name:         "Retry-After exceeds maximum delay",
+			retryAfter:   "30",
+			retries:      3,
+			maxDelay:     2 * time.Second,
+			errs:         []error{rateLimited},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "rate limit lifted",
+			retries:      3,
+			maxDelay:     time.Second,
+			errs:         []error{rateLimited, rateLimited},
+			wantAttempts: 3,
+		},
+		{
+			name:         "out of attempts",
+			retryAfter:   "0",
+			retries:      2,
+			maxDelay:     time.Second,
+			errs:         []error{rateLimited, rateLimited},
+			wantAttempts: 2,
+			wantErr:      true,
+		},
+		{
+			name:         "not retryable",
+			retries:      3,
+			maxDelay:     time.Second,
+			errs:         []error{forbidden},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSpotifyClient(t, tt.retryAfter, tt.retries, tt.maxDelay)
+
+			attempts := 0
+			op := func() error {
+				attempts++
+				if attempts <= len(tt.errs) {
+					return tt.errs[attempts-1]
+				}
+				return nil
+			}
+
+			start := time.Now()
+			s.Lock()
+			err := s.retry(op)
+			s.Unlock()
+			waited := time.Since(start)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("retry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if waited < tt.wantWait {
+				t.Errorf("waited %s, want at least %s", waited, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{value: "3", want: 3 * time.Second, wantOK: true},
+		{value: "0", want: 0, wantOK: true},
+		{value: "Mon, 19 Oct 2026 10:00:05 GMT", want: 5 * time.Second, wantOK: true},
+		{value: "Mon, 19 Oct 2026 09:59:00 GMT", want: 0, wantOK: true},
+		{value: "-1"},
+		{value: "soon"},
+		{value: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseRetryAfter() = %s, %v, want %s, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package alertify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// DefaultMinBackoff is default initial monitor restart delay
+	DefaultMinBackoff = 1 * time.Second
+	// DefaultMaxBackoff is default maximum monitor restart delay
+	DefaultMaxBackoff = 1 * time.Minute
+)
+
+// SupervisorConfig configures supervision of Bot monitors
+type SupervisorConfig struct {
+	// MinBackoff is the initial delay before restarting a failed monitor
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum delay before restarting a failed monitor.
+	// Monitor which runs longer than MaxBackoff is considered healthy again.
+	MaxBackoff time.Duration
+	// MaxRestarts is the maximum number of consecutive monitor restarts.
+	// Zero value means failed monitors are restarted indefinitely.
+	MaxRestarts int
+	// StopOnMaxRestarts stops the bot when a monitor exceeds MaxRestarts.
+	// Otherwise the monitor is marked as failed and the bot keeps running.
+	StopOnMaxRestarts bool
+	// StopOnFatal stops the bot when a monitor fails with fatal error
+	StopOnFatal bool
+}
+
+// defaultSupervisorConfig returns default supervisor configuration
+func defaultSupervisorConfig() *SupervisorConfig {
+	return &SupervisorConfig{
+		MinBackoff:  DefaultMinBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+		StopOnFatal: true,
+	}
+}
+
+// FatalError is a monitor error which can't be recovered by restarting the monitor
+type FatalError struct {
+	// Err is the underlying error
+	Err error
+}
+
+// Error implements error interface
+func (e *FatalError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *FatalError) Unwrap() error {
+	return e.Err
+}
+
+// Fatal marks err as fatal
+func Fatal(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &FatalError{Err: err}
+}
+
+// IsFatal returns true if err has been marked as fatal
+func IsFatal(err error) bool {
+	var fatal *FatalError
+	return errors.As(err, &fatal)
+}
+
+// supervise runs the monitor and restarts it with backoff when it fails
+// Errors which should stop the bot are sent to errChan.
+func (b *Bot) supervise(e *monitorEntry, errChan chan<- error) {
+	cfg := b.supervisor
+	bo := newBackoff(cfg.MinBackoff, cfg.MaxBackoff)
+
+	for {
+		// monitor might have been stopped while waiting for restart
+		if e.stopped() {
+			return
+		}
+
+		log.Printf("Starting %s", e)
+		started := time.Now()
+		err := e.run()
+		log.Printf("%s stopped", e)
+
+		if e.stopped() {
+			return
+		}
+
+		if err == nil {
+			err = fmt.Errorf("monitor exited unexpectedly")
+		}
+		log.Printf("%s failed: %s", e, err)
+
+		if IsFatal(err) {
+			e.fail(err)
+			if cfg.StopOnFatal {
+				sendErr(errChan, fmt.Errorf("%s: %w", e, err))
+			}
+			return
+		}
+
+		// monitor which ran long enough is considered healthy
+		if time.Since(started) > cfg.MaxBackoff {
+			bo.Reset()
+		}
+
+		if cfg.MaxRestarts > 0 && bo.Attempts() >= cfg.MaxRestarts {
+			e.fail(err)
+			if cfg.StopOnMaxRestarts {
+				sendErr(errChan, fmt.Errorf("%s: too many restarts: %w", e, err))
+			}
+			return
+		}
+
+		delay := bo.Next()
+		e.degrade(err, delay+cfg.MaxBackoff)
+		log.Printf("Restarting %s in %s", e, delay)
+
+		select {
+		case <-time.After(delay):
+		case <-e.doneChan:
+			return
+		}
+	}
+}
+
+// sendErr sends err to errChan unless there is an error already waiting in it
+func sendErr(errChan chan<- error, err error) {
+	select {
+	case errChan <- err:
+	default:
+	}
+}
@@ -0,0 +1,212 @@
+package alertify
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedMonitor is a monitor which fails with scripted errors and then runs until it's stopped
+type scriptedMonitor struct {
+	// errs are errors returned by subsequent runs
+	errs []error
+	// runs counts monitor runs
+	runs int
+	// stopChan stops the monitor
+	stopChan chan struct{}
+	// mutex
+	*sync.Mutex
+}
+
+// newScriptedMonitor creates new scripted monitor and returns it
+func newScriptedMonitor(errs ...error) *scriptedMonitor {
+	return &scriptedMonitor{
+		errs:     errs,
+		stopChan: make(chan struct{}, 1),
+		Mutex:    &sync.Mutex{},
+	}
+}
+
+// MonitorAndAlert returns the next scripted error or waits until the monitor is stopped
+func (m *scriptedMonitor) MonitorAndAlert(msgChan chan<- *Msg) error {
+	m.Lock()
+	run := m.runs
+	m.runs++
+	m.Unlock()
+
+	if run < len(m.errs) {
+		return m.errs[run]
+	}
+
+	<-m.stopChan
+	return nil
+}
+
+// Stop stops the running monitor
+func (m *scriptedMonitor) Stop() {
+	select {
+	case m.stopChan <- struct{}{}:
+	default:
+	}
+}
+
+// String returns the name of the monitor
+func (m *scriptedMonitor) String() string {
+	return "Scripted Monitor"
+}
+
+// started returns the number of monitor runs
+func (m *scriptedMonitor) started() int {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.runs
+}
+
+func TestSupervise(t *testing.T) {
+	errFailed := fmt.Errorf("connection reset by peer")
+
+	tests := []struct {
+		name         string
+		config       *SupervisorConfig
+		errs         []error
+		wantState    string
+		wantRuns     int
+		wantRestarts int
+		wantBotErr   bool
+	}{
+		{
+			name:         "restarted",
+			config:       &SupervisorConfig{MaxRestarts: 3},
+			errs:         []error{errFailed, nil},
+			wantState:    MonitorDegraded,
+			wantRuns:     3,
+			wantRestarts: 2,
+		},
+		{
+			name:         "too many restarts stop bot",
+			config:       &SupervisorConfig{MaxRestarts: 2, StopOnMaxRestarts: true},
+			errs:         []error{errFailed, errFailed, errFailed},
+			wantState:    MonitorFailed,
+			wantRuns:     3,
+			wantRestarts: 2,
+			wantBotErr:   true,
+		},
+		{
+			name:         "too many restarts",
+			config:       &SupervisorConfig{MaxRestarts: 2},
+			errs:         []error{errFailed, errFailed, errFailed},
+			wantState:    MonitorFailed,
+			wantRuns:     3,
+			wantRestarts: 2,
+		},
+		{
+			name:       "fatal error stops bot",
+			config:     &SupervisorConfig{StopOnFatal: true},
+			errs:       []error{Fatal(errFailed)},
+			wantState:  MonitorFailed,
+			wantRuns:   1,
+			wantBotErr: true,
+		},
+		{
+			name:         "fatal error after restart",
+			config:       &SupervisorConfig{},
+			errs:         []error{errFailed, Fatal(errFailed)},
+			wantState:    MonitorFailed,
+			wantRuns:     2,
+			wantRestarts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.MinBackoff = time.Millisecond
+			tt.config.MaxBackoff = time.Minute
+			b := &Bot{supervisor: tt.config}
+
+			m := newScriptedMonitor(tt.errs...)
+			e := newMonitorEntry("scripted", m)
+			e.isRunning = true
+
+			errChan := make(chan error, 1)
+			superviseDone := make(chan struct{})
+			go func() {
+				defer close(superviseDone)
+				b.supervise(e, errChan)
+			}()
+
+			// monitor which doesn't fail keeps running until it's stopped
+			deadline := time.Now().Add(5 * time.Second)
+			for m.started() < tt.wantRuns && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			if tt.wantState != MonitorFailed {
+				// let the supervisor update the state of the restarted monitor
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			status := e.Status()
+			if status.State != tt.wantState {
+				t.Errorf("state = %s, want %s", status.State, tt.wantState)
+			}
+			if status.Restarts != tt.wantRestarts {
+				t.Errorf("restarts = %d, want %d", status.Restarts, tt.wantRestarts)
+			}
+			if status.Error == "" {
+				t.Errorf("monitor error not reported")
+			}
+
+			e.stop()
+			select {
+			case <-superviseDone:
+			case <-time.After(5 * time.Second):
+				t.Fatal("supervisor not stopped")
+			}
+
+			if runs := m.started(); runs != tt.wantRuns {
+				t.Errorf("runs = %d, want %d", runs, tt.wantRuns)
+			}
+
+			select {
+			case err := <-errChan:
+				if !tt.wantBotErr {
+					t.Errorf("bot stopped with error: %v", err)
+				}
+			default:
+				if tt.wantBotErr {
+					t.Errorf("bot not stopped")
+				}
+			}
+		})
+	}
+}
+
+func TestSuperviseStoppedWhileWaiting(t *testing.T) {
+	b := &Bot{supervisor: &SupervisorConfig{MinBackoff: time.Minute, MaxBackoff: time.Minute}}
+
+	m := newScriptedMonitor(fmt.Errorf("connection reset by peer"))
+	e := newMonitorEntry("scripted", m)
+	e.isRunning = true
+
+	superviseDone := make(chan struct{})
+	go func() {
+		defer close(superviseDone)
+		b.supervise(e, make(chan error, 1))
+	}()
+
+	for e.Status().Restarts == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	e.stop()
+
+	select {
+	case <-superviseDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor waits for restart of stopped monitor")
+	}
+
+	if runs := m.started(); runs != 1 {
+		t.Errorf("runs = %d, want 1", runs)
+	}
+}
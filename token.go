@@ -0,0 +1,77 @@
+package alertify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/oauth2"
+)
+
+// loadToken reads Spotify OAuth token from file stored in path
+func loadToken(path string) (*oauth2.Token, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tok := new(oauth2.Token)
+	if err := json.Unmarshal(data, tok); err != nil {
+		return nil, err
+	}
+
+	return tok, nil
+}
+
+// saveToken stores Spotify OAuth token in file stored in path
+// The token file is only readable by the current user.
+func saveToken(path string, tok *oauth2.Token) error {
+	data, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+
+	// write the token to temporary file first so we don't end up with a corrupted token
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// persistToken stores Spotify OAuth token if it has been refreshed since it was last stored
+// It is called after every Spotify API call, so a token refreshed by any of them is not lost
+// on restart. It must be called with client lock held.
+func (s *SpotifyClient) persistToken() {
+	if s.tokenFile == "" {
+		return
+	}
+
+	tok, err := s.Token()
+	if err != nil {
+		log.Printf("Failed to read Spotify OAuth token: %s", err)
+		return
+	}
+
+	if s.token != nil && s.token.AccessToken == tok.AccessToken {
+		return
+	}
+
+	if err := saveToken(s.tokenFile, tok); err != nil {
+		log.Printf("Failed to store Spotify OAuth token in %s: %s", s.tokenFile, err)
+		return
+	}
+	s.token = tok
+}
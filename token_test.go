@@ -0,0 +1,98 @@
+package alertify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify"
+	"golang.org/x/oauth2"
+)
+
+func TestSaveToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alertify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "token.json")
+	tok := &oauth2.Token{AccessToken: "access-token", RefreshToken: "refresh-token", TokenType: "Bearer"}
+
+	if err := saveToken(path, tok); err != nil {
+		t.Fatalf("saveToken() error = %v", err)
+	}
+
+	got, err := loadToken(path)
+	if err != nil {
+		t.Fatalf("loadToken() error = %v", err)
+	}
+	if got.AccessToken != tok.AccessToken || got.RefreshToken != tok.RefreshToken {
+		t.Errorf("loadToken() = %+v, want %+v", got, tok)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("temporary token file left behind: %d files in token dir", len(files))
+	}
+}
+
+func TestSpotifyClientPersistToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    *oauth2.Token
+		wantSaved bool
+	}{
+		{
+			name:      "no stored token",
+			wantSaved: true,
+		},
+		{
+			name:      "refreshed token",
+			stored:    &oauth2.Token{AccessToken: "old-access-token"},
+			wantSaved: true,
+		},
+		{
+			name:   "unchanged token",
+			stored: &oauth2.Token{AccessToken: "access-token"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "alertify")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.RemoveAll(dir) })
+
+			client := spotify.NewAuthenticator("").NewClient(&oauth2.Token{
+				AccessToken: "access-token",
+				TokenType:   "Bearer",
+				Expiry:      time.Now().Add(time.Hour),
+			})
+			s := &SpotifyClient{
+				Client:    &client,
+				tokenFile: filepath.Join(dir, "token.json"),
+				token:     tt.stored,
+				Mutex:     &sync.Mutex{},
+			}
+
+			s.persistToken()
+
+			tok, err := loadToken(s.tokenFile)
+			if saved := err == nil; saved != tt.wantSaved {
+				t.Fatalf("token saved = %v, want %v", saved, tt.wantSaved)
+			}
+			if tt.wantSaved && (tok.AccessToken != "access-token" || s.token.AccessToken != "access-token") {
+				t.Errorf("stored token = %+v, client token = %+v", tok, s.token)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package alertify
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// VolumeRampStep is the interval between volume changes during volume ramp
+	VolumeRampStep = 500 * time.Millisecond
+	// VolumeRampStart is the volume in percent volume ramp starts from
+	VolumeRampStart = 5
+)
+
+// alertVolume computes the alert volume for song given current device volume
+// It returns false if the song doesn't change device volume.
+func alertVolume(song *Song, current int) (int, bool) {
+	if song.Volume == 0 {
+		return 0, false
+	}
+
+	volume := song.Volume
+	if song.RelativeVolume {
+		volume += current
+	}
+
+	return ClampVolume(volume), true
+}
+
+// ClampVolume clamps volume to 0-100 percent
+func ClampVolume(volume int) int {
+	switch {
+	case volume < 0:
+		return 0
+	case volume > 100:
+		return 100
+	}
+
+	return volume
+}
+
+// prepareVolume saves the current device volume and sets the alert volume before the song is played
+// It returns the alert volume and true if the volume should be changed after the song starts playing.
+func (b *Bot) prepareVolume(song *Song) (int, bool) {
+	if song.Volume == 0 {
+		return 0, false
+	}
+
+	current, err := b.client().DeviceVolume()
+	if err != nil {
+		log.Printf("Failed to read Spotify device volume: %s", err)
+		return 0, false
+	}
+
+	volume, _ := alertVolume(song, current)
+
+	b.Lock()
+	// volume has already been saved by an alert which is still playing
+	if b.volume == nil {
+		b.volume = &current
+	}
+	b.Unlock()
+
+	start := volume
+	if song.VolumeRamp > 0 && VolumeRampStart < volume {
+		start = VolumeRampStart
+	}
+
+	// device which is not playing might not accept volume changes, so set the volume
+	// again once the song starts playing
+	if err := b.client().SetVolume(start); err != nil {
+		log.Printf("Failed to set Spotify device volume: %s", err)
+	}
+
+	return volume, true
+}
+
+// rampVolume raises the device volume to volume over duration d
+// It stops when doneChan is closed.
+func (b *Bot) rampVolume(volume int, d time.Duration, doneChan chan struct{}) {
+	start := VolumeRampStart
+	if start > volume {
+		start = volume
+	}
+
+	steps := int(d / VolumeRampStep)
+	if steps < 1 {
+		steps = 1
+	}
+
+	ticker := time.NewTicker(VolumeRampStep)
+	defer ticker.Stop()
+
+	for i := 1; i <= steps; i++ {
+		select {
+		case <-ticker.C:
+			v := start + (volume-start)*i/steps
+			if err := b.client().SetVolume(v); err != nil {
+				log.Printf("Failed to ramp Spotify device volume: %s", err)
+			}
+		case <-doneChan:
+			return
+		}
+	}
+}
+
+// restoreVolume restores device volume saved before the alert
+func (b *Bot) restoreVolume() error {
+	b.Lock()
+	volume := b.volume
+	b.volume = nil
+	b.Unlock()
+
+	if volume == nil {
+		return nil
+	}
+
+	log.Printf("Restoring Spotify device volume to %d%%", *volume)
+
+	return b.client().SetVolume(*volume)
+}